@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// dominantFrequencyWindow is the length of signal examined by EstimateDominantFrequency.
+const dominantFrequencyWindow = 30 * time.Second
+
+// EstimateDominantFrequency estimates the dominant frequency (in Hz) of a signal using
+// a zero-crossing count over the first 30 seconds of the recording.
+//
+// The mean is removed from the window, rising zero crossings are located to sub-sample
+// precision by linear interpolation, and the frequency is taken as the number of whole
+// cycles between the first and last crossing divided by the time between them. This is
+// only meaningful for signals dominated by a single periodic component (e.g. 50/60 Hz
+// mains interference), but that is enough to catch a declared sample rate that doesn't
+// match the signal content.
+func (er *Reader) EstimateDominantFrequency(signalIndex int) (float64, error) {
+	sr, err := er.Signal(signalIndex)
+	if err != nil {
+		return 0, err
+	}
+
+	rate := er.hdr.SampleRate(signalIndex)
+	if rate <= 0 {
+		return 0, fmt.Errorf("signal has no sample rate")
+	}
+
+	samples := make([]float64, int(dominantFrequencyWindow.Seconds()*rate))
+	n, err := sr.Read(samples)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	samples = samples[:n]
+
+	var mean float64
+	for _, sample := range samples {
+		mean += sample
+	}
+	mean /= float64(len(samples))
+
+	var crossings int
+	var first, last float64
+	for i := 1; i < len(samples); i++ {
+		a, b := samples[i-1]-mean, samples[i]-mean
+		if a < 0 && b >= 0 {
+			// Interpolate the position of the crossing between the two samples.
+			pos := float64(i-1) + a/(a-b)
+			if crossings == 0 {
+				first = pos
+			}
+			last = pos
+			crossings++
+		}
+	}
+
+	if crossings < 2 {
+		return 0, fmt.Errorf("not enough zero crossings to estimate frequency")
+	}
+
+	return float64(crossings-1) * rate / (last - first), nil
+}
+
+// Stats summarizes the samples of a signal within an epoch.
+type Stats struct {
+	Start time.Duration // Start of the epoch relative to the start of the recording
+	Count int           // Number of samples in the epoch
+	Min   float64       // Minimum sample value
+	Max   float64       // Maximum sample value
+	Mean  float64       // Mean sample value
+	RMS   float64       // Root mean square of the sample values
+}
+
+// EpochStats divides a signal into consecutive epochs of the given length (commonly
+// 30 seconds for sleep scoring) and returns summary statistics for each. The epoch is
+// rounded to a whole number of samples and epochs are aligned to the start of the
+// recording, so each Start is the time of the epoch's first sample. If the recording
+// doesn't divide evenly, the final epoch is shorter and its Count reflects the samples
+// it holds. The data records are taken to follow on from one another, so any gaps in an
+// EDF+D file are ignored. The signal is processed in a single streaming pass.
+func (er *Reader) EpochStats(signalIndex int, epoch time.Duration) ([]Stats, error) {
+	sr, err := er.Signal(signalIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	epochSamples := int(math.Round(epoch.Seconds() * er.hdr.SampleRate(signalIndex)))
+	if epochSamples < 1 {
+		return nil, fmt.Errorf("epoch %s is shorter than one sample", epoch)
+	}
+
+	var stats []Stats
+	samples := make([]float64, epochSamples)
+	for {
+		n, err := sr.Read(samples)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		if n > 0 {
+			s := Stats{
+				Start: er.hdr.sampleOffset(signalIndex, int64(len(stats))*int64(epochSamples)),
+				Count: n,
+				Min:   math.Inf(1),
+				Max:   math.Inf(-1),
+			}
+
+			var sum, sumSquares float64
+			for _, sample := range samples[:n] {
+				s.Min = math.Min(s.Min, sample)
+				s.Max = math.Max(s.Max, sample)
+				sum += sample
+				sumSquares += sample * sample
+			}
+			s.Mean = sum / float64(n)
+			s.RMS = math.Sqrt(sumSquares / float64(n))
+
+			stats = append(stats, s)
+		}
+
+		if err != nil {
+			return stats, nil
+		}
+	}
+}
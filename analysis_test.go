@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateDominantFrequency(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  256,
+			},
+		},
+	}
+
+	// 10 seconds of 50 Hz mains interference.
+	var records [][][]float64
+	for r := 0; r < 10; r++ {
+		record := make([]float64, 256)
+		for i := range record {
+			t := float64(r*256+i) / 256
+			record[i] = 200 * math.Sin(2*math.Pi*50*t)
+		}
+		records = append(records, [][]float64{record})
+	}
+
+	er, err := edf.Open(writeTestFile(t, hdr, records))
+	require.NoError(t, err)
+
+	freq, err := er.EstimateDominantFrequency(0)
+	require.NoError(t, err)
+	require.InDelta(t, 50.0, freq, 0.1)
+}
+
+func TestEpochStats(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  100,
+			},
+		},
+	}
+
+	// 70 seconds of a square wave alternating between 100 and -100 (plus an offset of
+	// 10 times the epoch number), giving two full 30 second epochs and a partial one.
+	var records [][][]float64
+	for r := 0; r < 70; r++ {
+		record := make([]float64, 100)
+		for i := range record {
+			record[i] = float64(10 * (r / 30))
+			if i%2 == 0 {
+				record[i] += 100
+			} else {
+				record[i] -= 100
+			}
+		}
+		records = append(records, [][]float64{record})
+	}
+
+	er, err := edf.Open(writeTestFile(t, hdr, records))
+	require.NoError(t, err)
+
+	stats, err := er.EpochStats(0, 30*time.Second)
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+
+	for i, s := range stats {
+		offset := float64(10 * i)
+		require.Equal(t, time.Duration(i)*30*time.Second, s.Start)
+		require.InDelta(t, 100+offset, s.Max, 0.5)
+		require.InDelta(t, -100+offset, s.Min, 0.5)
+		require.InDelta(t, offset, s.Mean, 0.5)
+		require.InDelta(t, math.Sqrt(100*100+offset*offset), s.RMS, 0.5)
+	}
+
+	require.Equal(t, 3000, stats[0].Count)
+	require.Equal(t, 1000, stats[2].Count)
+
+	// At 3 Hz a 1.4 second epoch rounds to 4 samples, so epochs start every 4/3 seconds.
+	hdr.Signals[0].SamplesPerRecord = 3
+	er, err = edf.Open(writeTestFile(t, hdr, [][][]float64{
+		{{0, 0, 0}}, {{0, 0, 0}}, {{0, 0, 0}}, {{0, 0, 0}},
+	}))
+	require.NoError(t, err)
+
+	stats, err = er.EpochStats(0, 1400*time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+
+	for i, s := range stats {
+		require.Equal(t, 4, s.Count)
+		require.Equal(t, time.Duration(i)*4*time.Second/3, s.Start)
+	}
+}
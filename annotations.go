@@ -0,0 +1,537 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Annotation is a single EDF+ time-stamped annotation.
+type Annotation struct {
+	Onset    time.Duration // Onset of the event relative to the start of the recording
+	Duration time.Duration // Duration of the event, 0 if not specified
+	Texts    []string      // Annotation texts sharing the onset and duration
+}
+
+// Annotations returns every annotation stored in the file's EDF Annotations signals, in
+// file order. The timekeeping annotation at the start of each record is not included.
+// A file without an annotations signal has no annotations.
+func (er *Reader) Annotations() ([]Annotation, error) {
+	records, err := er.AnnotationsByRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	var annotations []Annotation
+	for _, record := range records {
+		annotations = append(annotations, record...)
+	}
+
+	return annotations, nil
+}
+
+// AnnotationsByRecord returns the annotations stored in the file, partitioned by the
+// data record that carried them. This is useful for EDF+D files, where each record
+// covers its own stretch of time. The timekeeping annotation at the start of each
+// record is not included in the output.
+func (er *Reader) AnnotationsByRecord() ([][]Annotation, error) {
+	var annotationSignals []int
+	for i, signal := range er.hdr.Signals {
+		if signal.IsAnnotations() {
+			annotationSignals = append(annotationSignals, i)
+		}
+	}
+
+	records := make([][]Annotation, er.dataRecords())
+	if len(annotationSignals) == 0 {
+		return records, nil
+	}
+
+	b := make([]byte, er.hdr.recordSize(er.sampleWidth))
+	for record := range records {
+		if err := er.readRecord(record, b); err != nil {
+			return nil, err
+		}
+
+		for n, signalIndex := range annotationSignals {
+			annotations, err := parseTALs(er.hdr.signalBlock(b, signalIndex, er.sampleWidth), n == 0)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing annotations in record %d: %w", record, err)
+			}
+
+			records[record] = append(records[record], annotations...)
+		}
+	}
+
+	return records, nil
+}
+
+// TimedEvent is an annotation placed at an absolute time.
+type TimedEvent struct {
+	Time     time.Time     // Wall-clock time of the event, zero if the start date is unknown
+	Onset    time.Duration // Onset of the event relative to the start of the recording
+	Duration time.Duration // Duration of the event, 0 if not specified
+	Text     string        // Annotation text
+	Relative bool          // The recording's start date is unknown, so only Onset is meaningful
+}
+
+// AnnotationTimeline returns every annotation in the file as a timeline of events at
+// absolute times, one event per annotation text, in file order. EDF+ onsets are always
+// relative to the start of the recording, even across the gaps of an EDF+D file, so the
+// absolute time is the start time plus the onset. If the EDF+ recording identification
+// declares the start date unknown ("Startdate X"), events are marked Relative and carry
+// only their onset.
+func (er *Reader) AnnotationTimeline() ([]TimedEvent, error) {
+	records, err := er.AnnotationsByRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	relative := startDateUnknown(er.hdr.RecordingID)
+
+	var events []TimedEvent
+	for _, annotations := range records {
+		for _, annotation := range annotations {
+			for _, text := range annotation.Texts {
+				event := TimedEvent{
+					Onset:    annotation.Onset,
+					Duration: annotation.Duration,
+					Text:     text,
+					Relative: relative,
+				}
+				if !relative {
+					event.Time = er.hdr.StartTime.Add(annotation.Onset)
+				}
+				events = append(events, event)
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// AnnotationsInRange returns the annotations whose onset falls within [start, end),
+// relative to the start of the recording. Records are scanned in order and scanning
+// stops at the first record whose timekeeping annotation starts at or after end, so
+// only the records up to the end of the window are read. This relies on annotations
+// being stored no later than the record covering their onset, which holds for files
+// written by well behaved EDF+C and EDF+D writers, though in EDF+D files the gaps
+// between records mean a window may span fewer records than its length suggests.
+func (er *Reader) AnnotationsInRange(start, end time.Duration) ([]Annotation, error) {
+	var annotationSignals []int
+	for i, signal := range er.hdr.Signals {
+		if signal.IsAnnotations() {
+			annotationSignals = append(annotationSignals, i)
+		}
+	}
+
+	if len(annotationSignals) == 0 {
+		return nil, nil
+	}
+
+	var annotations []Annotation
+	b := make([]byte, er.hdr.recordSize(er.sampleWidth))
+	for record := 0; record < er.dataRecords(); record++ {
+		if err := er.readRecord(record, b); err != nil {
+			return nil, err
+		}
+
+		recordStart, err := parseTimekeeping(er.hdr.signalBlock(b, annotationSignals[0], er.sampleWidth))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing annotations in record %d: %w", record, err)
+		}
+		if recordStart >= end {
+			break
+		}
+
+		for n, signalIndex := range annotationSignals {
+			parsed, err := parseTALs(er.hdr.signalBlock(b, signalIndex, er.sampleWidth), n == 0)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing annotations in record %d: %w", record, err)
+			}
+
+			for _, annotation := range parsed {
+				if annotation.Onset >= start && annotation.Onset < end {
+					annotations = append(annotations, annotation)
+				}
+			}
+		}
+	}
+
+	return annotations, nil
+}
+
+// parseTimekeeping returns the onset of the timekeeping TAL at the start of an
+// annotation signal's block, i.e. the start time of the data record.
+func parseTimekeeping(b []byte) (time.Duration, error) {
+	end := bytes.IndexAny(b, "\x14\x15")
+	if end < 0 {
+		return 0, fmt.Errorf("missing timekeeping annotation")
+	}
+
+	onset, err := parseTALTime(b[:end], true)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timekeeping onset %q: %w", b[:end], err)
+	}
+
+	return onset, nil
+}
+
+// AttachAnnotations writes an EDF+ file to dst combining the signals of data, a file
+// with no annotation signals, with the annotation signals of annotations, an EDF+ file
+// containing only annotation signals, such as separately stored scoring. Both files
+// must share the same start time and data record duration. The annotations file may
+// have fewer data records than the data file, in which case the remaining records are
+// given a bare timekeeping annotation. The output is marked as continuous (EDF+C)
+// unless the annotations file is discontinuous (EDF+D).
+func AttachAnnotations(dst io.WriteSeeker, data *Reader, annotations *Reader) error {
+	for i, signal := range data.hdr.Signals {
+		if signal.IsAnnotations() {
+			return fmt.Errorf("data file already has an annotations signal at index %d", i)
+		}
+	}
+	if len(annotations.hdr.Signals) == 0 {
+		return fmt.Errorf("annotations file has no signals")
+	}
+	for i, signal := range annotations.hdr.Signals {
+		if !signal.IsAnnotations() {
+			return fmt.Errorf("annotations file has a data signal %q at index %d", signal.Label, i)
+		}
+	}
+
+	if !data.hdr.StartTime.Equal(annotations.hdr.StartTime) {
+		return fmt.Errorf("start times differ: %s != %s", data.hdr.StartTime, annotations.hdr.StartTime)
+	}
+	if data.hdr.DataRecordDuration != annotations.hdr.DataRecordDuration {
+		return fmt.Errorf("data record durations differ: %s != %s", data.hdr.DataRecordDuration, annotations.hdr.DataRecordDuration)
+	}
+	if annotations.dataRecords() > data.dataRecords() {
+		return fmt.Errorf("annotations file has %d data records, more than the %d of the data file", annotations.dataRecords(), data.dataRecords())
+	}
+
+	hdr := *data.hdr
+	hdr.Signals = append(append([]SignalHeader(nil), data.hdr.Signals...), annotations.hdr.Signals...)
+	hdr.SignalCount = len(hdr.Signals)
+	hdr.Type = EDFPlusContinuous
+	if annotations.hdr.Type == EDFPlusDiscontinuous {
+		hdr.Type = EDFPlusDiscontinuous
+	}
+
+	ew, err := Create(dst, hdr)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, data.hdr.recordSize(data.sampleWidth)+annotations.hdr.recordSize(annotations.sampleWidth))
+	dataRecord, annotationsRecord := b[:data.hdr.recordSize(data.sampleWidth)], b[data.hdr.recordSize(data.sampleWidth):]
+	for record := 0; record < data.dataRecords(); record++ {
+		if err := data.readRecord(record, dataRecord); err != nil {
+			return fmt.Errorf("error reading data record %d: %w", record, err)
+		}
+
+		if record < annotations.dataRecords() {
+			if err := annotations.readRecord(record, annotationsRecord); err != nil {
+				return fmt.Errorf("error reading annotations record %d: %w", record, err)
+			}
+		} else {
+			tal := formatTALTime(time.Duration(record)*hdr.DataRecordDuration, true) + "\x14\x14\x00"
+			if len(tal) > len(annotationsRecord) {
+				return fmt.Errorf("no room for the timekeeping annotation of record %d", record)
+			}
+
+			for i := range annotationsRecord {
+				annotationsRecord[i] = 0
+			}
+			copy(annotationsRecord, tal)
+		}
+
+		if err := ew.writeRawRecord(b); err != nil {
+			return err
+		}
+	}
+
+	return ew.Close()
+}
+
+// WriteAnnotations queues EDF+ annotations to be written to the header's EDF
+// Annotations signal. Each data record written from then on carries, after the
+// mandatory timekeeping annotation giving the record's onset, as many of the queued
+// annotations as fit in the signal's SamplesPerRecord*2 bytes (3 for BDF), in the order
+// they were queued; the rest of the block is padded with zero bytes. Queued annotations
+// are therefore stored no later than the record covering their onset provided they are
+// queued before that record is written. It is an error to close the writer with
+// annotations still queued. Onsets are taken as relative to the start of the recording
+// and the data records as contiguous, so the header's Type should be EDFPlusContinuous.
+func (ew *Writer) WriteAnnotations(annotations []Annotation) error {
+	var found bool
+	for _, signal := range ew.hdr.Signals {
+		found = found || signal.IsAnnotations()
+	}
+	if !found {
+		return fmt.Errorf("header has no %q signal", AnnotationsLabel)
+	}
+
+	for i, annotation := range annotations {
+		if annotation.Duration < 0 {
+			return fmt.Errorf("annotation %d has a negative duration", i)
+		}
+		if len(annotation.Texts) == 0 {
+			return fmt.Errorf("annotation %d has no text", i)
+		}
+		for _, text := range annotation.Texts {
+			if strings.ContainsAny(text, "\x00\x14\x15") {
+				return fmt.Errorf("annotation %d text %q contains a TAL delimiter", i, text)
+			}
+		}
+	}
+
+	ew.annotations = append(ew.annotations, annotations...)
+	return nil
+}
+
+// fillAnnotations fills an annotation signal's block for the next data record with the
+// record's timekeeping annotation, if requested, followed by as many of the queued
+// annotations as fit. It returns the number of queued annotations written, leaving it
+// to the caller to dequeue them once the record has been written.
+func (ew *Writer) fillAnnotations(block []byte, timekeeping bool, queued []Annotation) (int, error) {
+	var offset int
+	if timekeeping {
+		onset := time.Duration(ew.dataRecords) * ew.hdr.DataRecordDuration
+		tal := formatTALTime(onset, true) + "\x14\x14\x00"
+		if len(tal) > len(block) {
+			return 0, fmt.Errorf("no room for the timekeeping annotation of record %d", ew.dataRecords)
+		}
+		offset += copy(block, tal)
+	}
+
+	var written int
+	for _, annotation := range queued {
+		tal := encodeTAL(annotation)
+		if offset+len(tal) > len(block) {
+			// An annotation that doesn't fit in an otherwise empty block never will.
+			if written == 0 {
+				return 0, fmt.Errorf("annotation %q doesn't fit in a data record", tal)
+			}
+			break
+		}
+		offset += copy(block[offset:], tal)
+		written++
+	}
+
+	for i := offset; i < len(block); i++ {
+		block[i] = 0
+	}
+
+	return written, nil
+}
+
+// encodeTAL encodes an annotation as a Time-stamped Annotation List.
+func encodeTAL(annotation Annotation) string {
+	var sb strings.Builder
+	sb.WriteString(formatTALTime(annotation.Onset, true))
+	if annotation.Duration > 0 {
+		sb.WriteByte(0x15)
+		sb.WriteString(formatTALTime(annotation.Duration, false))
+	}
+	sb.WriteByte(0x14)
+	for _, text := range annotation.Texts {
+		sb.WriteString(text)
+		sb.WriteByte(0x14)
+	}
+	sb.WriteByte(0)
+	return sb.String()
+}
+
+// formatTALTime formats a TAL onset or duration in seconds, exactly to nanosecond
+// precision. Onsets carry an explicit sign, durations don't.
+func formatTALTime(d time.Duration, signed bool) string {
+	var sign string
+	if signed {
+		sign = "+"
+		if d < 0 {
+			sign = "-"
+			d = -d
+		}
+	}
+
+	s := sign + strconv.FormatInt(int64(d/time.Second), 10)
+	if fraction := d % time.Second; fraction != 0 {
+		s += "." + strings.TrimRight(fmt.Sprintf("%09d", int64(fraction)), "0")
+	}
+	return s
+}
+
+// parseTALs parses the Time-stamped Annotation Lists (TALs) in an annotation signal's
+// block from a single data record. If timekeeping is true, the first TAL is the
+// record's timekeeping annotation, whose leading empty text is dropped.
+func parseTALs(b []byte, timekeeping bool) ([]Annotation, error) {
+	var annotations []Annotation
+	for len(b) > 0 {
+		// Skip the unused (zero) bytes padding out the block.
+		if b[0] == 0 {
+			b = b[1:]
+			continue
+		}
+
+		end := bytes.IndexByte(b, 0)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated TAL")
+		}
+		tal := b[:end]
+		b = b[end+1:]
+
+		fields := bytes.Split(tal, []byte{0x14})
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("malformed TAL %q", tal)
+		}
+
+		var annotation Annotation
+		onset, duration, hasDuration := bytes.Cut(fields[0], []byte{0x15})
+
+		var err error
+		annotation.Onset, err = parseTALTime(onset, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid onset %q: %w", onset, err)
+		}
+
+		if hasDuration {
+			annotation.Duration, err = parseTALTime(duration, false)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration %q: %w", duration, err)
+			}
+		}
+
+		// The TAL is terminated by a 0x14, so the last field is always empty.
+		texts := fields[1 : len(fields)-1]
+		if timekeeping && len(texts) > 0 {
+			texts = texts[1:]
+		}
+		timekeeping = false
+
+		for _, text := range texts {
+			annotation.Texts = append(annotation.Texts, string(text))
+		}
+
+		if len(annotation.Texts) > 0 {
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	return annotations, nil
+}
+
+// shiftTALs rewrites the onset of every TAL in an annotation signal's block, the
+// timekeeping TAL included, to be shift earlier, leaving the rest of each TAL as is.
+// It is an error if the rewritten TALs no longer fit in the block.
+func shiftTALs(b []byte, shift time.Duration) error {
+	shifted := make([]byte, 0, len(b))
+	for rest := b; len(rest) > 0; {
+		// Skip the unused (zero) bytes padding out the block.
+		if rest[0] == 0 {
+			rest = rest[1:]
+			continue
+		}
+
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return fmt.Errorf("unterminated TAL")
+		}
+		tal := rest[:end+1]
+		rest = rest[end+1:]
+
+		onsetEnd := bytes.IndexAny(tal, "\x14\x15")
+		if onsetEnd < 0 {
+			return fmt.Errorf("malformed TAL %q", tal)
+		}
+
+		onset, err := parseTALTime(tal[:onsetEnd], true)
+		if err != nil {
+			return fmt.Errorf("invalid onset %q: %w", tal[:onsetEnd], err)
+		}
+
+		shifted = append(shifted, formatTALTime(onset-shift, true)...)
+		shifted = append(shifted, tal[onsetEnd:]...)
+	}
+
+	if len(shifted) > len(b) {
+		return fmt.Errorf("shifted annotations don't fit in a data record")
+	}
+
+	n := copy(b, shifted)
+	for i := n; i < len(b); i++ {
+		b[i] = 0
+	}
+
+	return nil
+}
+
+// parseTALTime parses a TAL onset or duration in seconds. Onsets must carry an explicit
+// sign, durations must not. The decimal value is converted exactly, without going via a
+// float, to nanosecond precision; any further digits are rounded.
+func parseTALTime(b []byte, signed bool) (time.Duration, error) {
+	if len(b) == 0 {
+		return 0, fmt.Errorf("empty value")
+	}
+
+	negative := false
+	if b[0] == '+' || b[0] == '-' {
+		if !signed {
+			return 0, fmt.Errorf("unexpected sign")
+		}
+		negative = b[0] == '-'
+		b = b[1:]
+	} else if signed {
+		return 0, fmt.Errorf("missing sign")
+	}
+
+	whole, fraction, _ := bytes.Cut(b, []byte{'.'})
+	if len(whole)+len(fraction) == 0 {
+		return 0, fmt.Errorf("no digits")
+	}
+
+	var seconds int64
+	for _, c := range whole {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid character %q", c)
+		}
+		seconds = seconds*10 + int64(c-'0')
+		if seconds > math.MaxInt64/int64(time.Second)-1 {
+			return 0, fmt.Errorf("value out of range")
+		}
+	}
+
+	var nanoseconds int64
+	for i, c := range fraction {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid character %q", c)
+		}
+		switch {
+		case i < 9:
+			nanoseconds = nanoseconds*10 + int64(c-'0')
+		case i == 9 && c >= '5':
+			nanoseconds++
+		}
+	}
+	for i := len(fraction); i < 9; i++ {
+		nanoseconds *= 10
+	}
+
+	d := time.Duration(seconds)*time.Second + time.Duration(nanoseconds)
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
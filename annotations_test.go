@@ -0,0 +1,452 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+// annotatedHeader describes a file with a single data signal of 4 samples per record
+// followed by an annotations signal with room for 60 bytes of TALs per record.
+var annotatedHeader = edf.Header{
+	Version:            edf.Version0,
+	StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+	DataRecordDuration: time.Second,
+	SignalCount:        2,
+	Signals: []edf.SignalHeader{
+		{
+			Label:             "EEG Fpz-Cz",
+			PhysicalDimension: "uV",
+			PhysicalMin:       -500,
+			PhysicalMax:       500,
+			DigitalMin:        -2048,
+			DigitalMax:        2047,
+			SamplesPerRecord:  4,
+		},
+		{
+			Label:            edf.AnnotationsLabel,
+			PhysicalMin:      -1,
+			PhysicalMax:      1,
+			DigitalMin:       -32768,
+			DigitalMax:       32767,
+			SamplesPerRecord: 30,
+		},
+	},
+}
+
+// annotatedRecord builds a raw data record for annotatedHeader, with zeroed samples
+// and the given TALs (which must include the timekeeping TAL).
+func annotatedRecord(tals ...string) []byte {
+	record := make([]byte, 8+60)
+	var offset int
+	for _, tal := range tals {
+		offset += copy(record[8+offset:], tal)
+	}
+	return record
+}
+
+func TestReaderAnnotationsByRecord(t *testing.T) {
+	f := writeRawTestFile(t, annotatedHeader, [][]byte{
+		annotatedRecord("+0\x14\x14\x00", "+0.5\x150.25\x14Lights off\x14\x00"),
+		annotatedRecord("+1\x14\x14\x00"),
+		annotatedRecord("+2\x14\x14Arousal\x14\x00", "+2.75\x14Apnea\x14Central\x14\x00"),
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	records, err := er.AnnotationsByRecord()
+	require.NoError(t, err)
+
+	require.Equal(t, [][]edf.Annotation{
+		{
+			{Onset: 500 * time.Millisecond, Duration: 250 * time.Millisecond, Texts: []string{"Lights off"}},
+		},
+		nil,
+		{
+			{Onset: 2 * time.Second, Texts: []string{"Arousal"}},
+			{Onset: 2750 * time.Millisecond, Texts: []string{"Apnea", "Central"}},
+		},
+	}, records)
+}
+
+func TestReaderAnnotations(t *testing.T) {
+	f := writeRawTestFile(t, annotatedHeader, [][]byte{
+		annotatedRecord("+0\x14\x14\x00", "-0.5\x14Recording started\x14\x00"),
+		annotatedRecord("+1\x14\x14\x00", "+1.5\x1530\x14Apnea\x14Obstructive\x14\x00"),
+		annotatedRecord("+2\x14\x14\x00"),
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	annotations, err := er.Annotations()
+	require.NoError(t, err)
+
+	require.Equal(t, []edf.Annotation{
+		{Onset: -500 * time.Millisecond, Texts: []string{"Recording started"}},
+		{Onset: 1500 * time.Millisecond, Duration: 30 * time.Second, Texts: []string{"Apnea", "Obstructive"}},
+	}, annotations)
+
+	// Onsets must be signed.
+	f = writeRawTestFile(t, annotatedHeader, [][]byte{
+		annotatedRecord("+0\x14\x14\x00", "1\x14Unsigned\x14\x00"),
+	})
+
+	er, err = edf.Open(f)
+	require.NoError(t, err)
+
+	_, err = er.Annotations()
+	require.ErrorContains(t, err, "missing sign")
+}
+
+func TestWriterWriteAnnotations(t *testing.T) {
+	hdr := annotatedHeader
+	hdr.Reserved = "EDF+C"
+
+	annotations := []edf.Annotation{
+		{Onset: -500 * time.Millisecond, Texts: []string{"Recording started"}},
+		{Onset: 500 * time.Millisecond, Duration: 250 * time.Millisecond, Texts: []string{"Lights off"}},
+		{Onset: 1123456789 * time.Nanosecond, Duration: 30 * time.Second, Texts: []string{"Apnea", "Obstructive"}},
+	}
+
+	f := createTestFile(t)
+	ew, err := edf.Create(f, hdr)
+	require.NoError(t, err)
+
+	require.NoError(t, ew.WriteAnnotations(annotations))
+
+	// Only as many annotations as fit go in each record, the rest spill into the next.
+	samples := []float64{1, 2, 3, 4}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, ew.WriteRecord([][]float64{samples, nil}))
+	}
+	require.NoError(t, ew.Close())
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	got, err := er.Annotations()
+	require.NoError(t, err)
+	require.Equal(t, annotations, got)
+
+	records, err := er.AnnotationsByRecord()
+	require.NoError(t, err)
+	require.Len(t, records[0], 2)
+	require.Len(t, records[1], 1)
+	require.Empty(t, records[2])
+
+	// The final, annotation free, record is still written.
+	end, err := er.EndTime()
+	require.NoError(t, err)
+	require.Equal(t, hdr.StartTime.Add(3*time.Second), end)
+
+	samplesRead, err := er.ReadAll(0)
+	require.NoError(t, err)
+	require.InDelta(t, 4, samplesRead[11], 1)
+
+	t.Run("Unwritten annotations", func(t *testing.T) {
+		ew, err := edf.Create(createTestFile(t), hdr)
+		require.NoError(t, err)
+
+		require.NoError(t, ew.WriteAnnotations(annotations))
+		require.NoError(t, ew.WriteRecord([][]float64{samples, nil}))
+		require.ErrorContains(t, ew.Close(), "1 annotations were not written")
+	})
+
+	t.Run("Failed record", func(t *testing.T) {
+		// Put the annotations signal first, so its block is filled before the record
+		// fails on the data signal.
+		hdr := hdr
+		hdr.Signals = []edf.SignalHeader{annotatedHeader.Signals[1], annotatedHeader.Signals[0]}
+
+		f := createTestFile(t)
+		ew, err := edf.Create(f, hdr)
+		require.NoError(t, err)
+
+		require.NoError(t, ew.WriteAnnotations(annotations))
+		require.Error(t, ew.WriteRecord([][]float64{nil, {1, math.NaN(), 3, 4}}))
+
+		// The annotations queued for the failed record go in the next one written.
+		for i := 0; i < 3; i++ {
+			require.NoError(t, ew.WriteRecord([][]float64{nil, samples}))
+		}
+		require.NoError(t, ew.Close())
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		er, err := edf.Open(f)
+		require.NoError(t, err)
+
+		got, err := er.Annotations()
+		require.NoError(t, err)
+		require.Equal(t, annotations, got)
+	})
+
+	t.Run("Too long", func(t *testing.T) {
+		ew, err := edf.Create(createTestFile(t), hdr)
+		require.NoError(t, err)
+
+		require.NoError(t, ew.WriteAnnotations([]edf.Annotation{
+			{Onset: time.Second, Texts: []string{strings.Repeat("x", 60)}},
+		}))
+		require.ErrorContains(t, ew.WriteRecord([][]float64{samples, nil}), "doesn't fit")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		ew, err := edf.Create(createTestFile(t), hdr)
+		require.NoError(t, err)
+
+		require.Error(t, ew.WriteAnnotations([]edf.Annotation{{Onset: time.Second}}))
+		require.Error(t, ew.WriteAnnotations([]edf.Annotation{{Onset: time.Second, Texts: []string{"a\x14b"}}}))
+	})
+}
+
+func TestReaderAnnotationsInRange(t *testing.T) {
+	f := writeRawTestFile(t, annotatedHeader, [][]byte{
+		annotatedRecord("+0\x14\x14\x00", "+0.5\x150.25\x14Lights off\x14\x00"),
+		annotatedRecord("+1\x14\x14\x00"),
+		annotatedRecord("+2\x14\x14Arousal\x14\x00", "+2.75\x14Apnea\x14Central\x14\x00"),
+		// Malformed, but past the end of the window so never parsed.
+		annotatedRecord("+3\x14\x14\x00", "+x\x14Bad\x14\x00"),
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	annotations, err := er.AnnotationsInRange(500*time.Millisecond, 2750*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, []edf.Annotation{
+		{Onset: 500 * time.Millisecond, Duration: 250 * time.Millisecond, Texts: []string{"Lights off"}},
+		{Onset: 2 * time.Second, Texts: []string{"Arousal"}},
+	}, annotations)
+
+	annotations, err = er.AnnotationsInRange(time.Second, 2*time.Second)
+	require.NoError(t, err)
+	require.Empty(t, annotations)
+
+	_, err = er.AnnotationsInRange(0, 4*time.Second)
+	require.Error(t, err)
+}
+
+func TestAttachAnnotations(t *testing.T) {
+	dataHeader := annotatedHeader
+	dataHeader.SignalCount = 1
+	dataHeader.Signals = annotatedHeader.Signals[:1]
+
+	records := make([][][]float64, 3)
+	for i := range records {
+		records[i] = [][]float64{{float64(i), 100, 200, 300}}
+	}
+
+	data, err := edf.Open(writeTestFile(t, dataHeader, records))
+	require.NoError(t, err)
+
+	annotationsHeader := annotatedHeader
+	annotationsHeader.Reserved = "EDF+C"
+	annotationsHeader.SignalCount = 1
+	annotationsHeader.Signals = annotatedHeader.Signals[1:]
+
+	// talBlock pads TALs out to the 60 byte annotations block.
+	talBlock := func(tals string) []byte {
+		b := make([]byte, 60)
+		copy(b, tals)
+		return b
+	}
+
+	annotations, err := edf.Open(writeRawTestFile(t, annotationsHeader, [][]byte{
+		talBlock("+0\x14\x14\x00+0.5\x14Lights off\x14\x00"),
+		talBlock("+1\x14\x14\x00+1.25\x150.5\x14Arousal\x14\x00"),
+	}))
+	require.NoError(t, err)
+
+	f := createTestFile(t)
+	require.NoError(t, edf.AttachAnnotations(f, data, annotations))
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	hdr := er.Header()
+	require.Equal(t, "EDF+C", hdr.Reserved)
+	require.Equal(t, 3, hdr.DataRecords)
+	require.Equal(t, []string{"EEG Fpz-Cz", edf.AnnotationsLabel}, []string{hdr.Signals[0].Label, hdr.Signals[1].Label})
+
+	byRecord, err := er.AnnotationsByRecord()
+	require.NoError(t, err)
+	require.Equal(t, [][]edf.Annotation{
+		{{Onset: 500 * time.Millisecond, Texts: []string{"Lights off"}}},
+		{{Onset: 1250 * time.Millisecond, Duration: 500 * time.Millisecond, Texts: []string{"Arousal"}}},
+		nil,
+	}, byRecord)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	samples := make([]float64, 12)
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	for i, record := range records {
+		for j, want := range record[0] {
+			require.InDelta(t, want, samples[i*4+j], 1.0)
+		}
+	}
+
+	// The recordings must share a timebase.
+	annotationsHeader.StartTime = annotationsHeader.StartTime.Add(time.Hour)
+	annotations, err = edf.Open(writeRawTestFile(t, annotationsHeader, nil))
+	require.NoError(t, err)
+	require.ErrorContains(t, edf.AttachAnnotations(createTestFile(t), data, annotations), "start times differ")
+}
+
+func TestReaderAnnotationsFractionalTimes(t *testing.T) {
+	hdr := annotatedHeader
+	hdr.Signals = append([]edf.SignalHeader(nil), annotatedHeader.Signals...)
+	hdr.Signals[1].SamplesPerRecord = 60
+
+	// record pads TALs out to the 120 byte annotations block.
+	record := func(tals ...string) []byte {
+		b := make([]byte, 8+120)
+		var offset int
+		for _, tal := range tals {
+			offset += copy(b[8+offset:], tal)
+		}
+		return b
+	}
+
+	f := writeRawTestFile(t, hdr, [][]byte{
+		record(
+			"+0\x14\x14\x00",
+			"+0.3\x150.0005\x14Spindle\x14\x00",
+			"+1234567.123456789\x14Precise\x14\x00",
+			"+2.0000000005\x15.25\x14Rounded\x14\x00",
+		),
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	records, err := er.AnnotationsByRecord()
+	require.NoError(t, err)
+	require.Equal(t, []edf.Annotation{
+		{Onset: 300 * time.Millisecond, Duration: 500 * time.Microsecond, Texts: []string{"Spindle"}},
+		{Onset: 1234567*time.Second + 123456789, Texts: []string{"Precise"}},
+		{Onset: 2*time.Second + 1, Duration: 250 * time.Millisecond, Texts: []string{"Rounded"}},
+	}, records[0])
+
+	f = writeRawTestFile(t, hdr, [][]byte{
+		record("+0\x14\x14\x00", "+1.2e3\x14Exponent\x14\x00"),
+	})
+
+	er, err = edf.Open(f)
+	require.NoError(t, err)
+
+	_, err = er.AnnotationsByRecord()
+	require.ErrorContains(t, err, "invalid onset")
+}
+
+func TestReaderAnnotationTimeline(t *testing.T) {
+	records := [][]byte{
+		annotatedRecord("+0\x14\x14\x00", "+0.5\x150.25\x14Lights off\x14\x00"),
+		annotatedRecord("+1\x14\x14\x00"),
+		annotatedRecord("+2\x14\x14Arousal\x14\x00", "+2.75\x14Apnea\x14Central\x14\x00"),
+	}
+
+	er, err := edf.Open(writeRawTestFile(t, annotatedHeader, records))
+	require.NoError(t, err)
+
+	start := annotatedHeader.StartTime
+
+	events, err := er.AnnotationTimeline()
+	require.NoError(t, err)
+	require.Equal(t, []edf.TimedEvent{
+		{Time: start.Add(500 * time.Millisecond), Onset: 500 * time.Millisecond, Duration: 250 * time.Millisecond, Text: "Lights off"},
+		{Time: start.Add(2 * time.Second), Onset: 2 * time.Second, Text: "Arousal"},
+		{Time: start.Add(2750 * time.Millisecond), Onset: 2750 * time.Millisecond, Text: "Apnea"},
+		{Time: start.Add(2750 * time.Millisecond), Onset: 2750 * time.Millisecond, Text: "Central"},
+	}, events)
+
+	// Without a known start date, only relative times are available.
+	hdr := annotatedHeader
+	hdr.RecordingID = "Startdate X X X X"
+
+	er, err = edf.Open(writeRawTestFile(t, hdr, records))
+	require.NoError(t, err)
+
+	events, err = er.AnnotationTimeline()
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	for _, event := range events {
+		require.True(t, event.Relative)
+		require.True(t, event.Time.IsZero())
+	}
+	require.Equal(t, 2*time.Second, events[1].Onset)
+}
+
+func TestSignalReaderTimeAt(t *testing.T) {
+	hdr := annotatedHeader
+	start := hdr.StartTime
+
+	// Contiguous records are assumed to follow on from one another.
+	er, err := edf.Open(writeRawTestFile(t, hdr, [][]byte{
+		annotatedRecord("+0\x14\x14\x00"),
+		annotatedRecord("+1\x14\x14\x00"),
+	}))
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	at, err := sr.TimeAt(6)
+	require.NoError(t, err)
+	require.Equal(t, start.Add(1500*time.Millisecond), at)
+
+	header := er.Header()
+	require.Equal(t, []time.Time{
+		start,
+		start.Add(250 * time.Millisecond),
+		start.Add(500 * time.Millisecond),
+		start.Add(750 * time.Millisecond),
+		start.Add(time.Second),
+	}, header.Times(0, 5))
+	require.Nil(t, header.Times(0, -1))
+
+	// Discontinuous records take their onset from their timekeeping annotation.
+	hdr.Type = edf.EDFPlusDiscontinuous
+	er, err = edf.Open(writeRawTestFile(t, hdr, [][]byte{
+		annotatedRecord("+0\x14\x14\x00"),
+		annotatedRecord("+10\x14\x14\x00"),
+		annotatedRecord("+20.5\x14\x14\x00"),
+	}))
+	require.NoError(t, err)
+
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+
+	at, err = sr.TimeAt(9)
+	require.NoError(t, err)
+	require.Equal(t, start.Add(20750*time.Millisecond), at)
+
+	_, err = sr.TimeAt(12)
+	require.Error(t, err)
+}
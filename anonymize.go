@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Anonymize strips patient and recording identifiers from a file in place, so it can be
+// shared. The patient identification is replaced with the EDF+ placeholders for unknown
+// subfields ("X X X X"). An EDF+ recording identification keeps its Startdate subfield,
+// so the file remains valid EDF+, with the remaining subfields replaced by "X"; any
+// other recording identification is replaced with "X". Only these header fields, and
+// the start time if WithMidnightStartTime is given, are overwritten: the fields keep
+// their width, so no byte offsets shift and the sample data is untouched.
+func Anonymize(rw io.ReadWriteSeeker, opts ...AnonymizeOption) error {
+	var o anonymizeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if _, err := rw.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking to header: %w", err)
+	}
+
+	b := make([]byte, 256)
+	if _, err := io.ReadFull(rw, b); err != nil {
+		return fmt.Errorf("error reading header: %w", err)
+	}
+
+	fields, err := splitFixedHeader(b)
+	if err != nil {
+		return err
+	}
+
+	recordingID := "X"
+	if subfields := strings.Fields(string(fields["RecordingID"])); len(subfields) >= 2 && subfields[0] == "Startdate" {
+		recordingID = fmt.Sprintf("Startdate %s X X X", subfields[1])
+	}
+
+	replacements := map[string]string{
+		"PatientID":   "X X X X",
+		"RecordingID": recordingID,
+	}
+	if o.midnight {
+		replacements["StartTime"] = "00.00.00"
+	}
+
+	layout := headerLayout(0)
+	for _, field := range fixedHeaderFields {
+		value, ok := replacements[field.name]
+		if !ok {
+			continue
+		}
+
+		if _, err := rw.Seek(int64(layout[field.name]), io.SeekStart); err != nil {
+			return fmt.Errorf("error seeking to %s: %w", field.name, err)
+		}
+		if _, err := io.WriteString(rw, fmt.Sprintf("%-*s", field.width, value)); err != nil {
+			return fmt.Errorf("error writing %s: %w", field.name, err)
+		}
+	}
+
+	return nil
+}
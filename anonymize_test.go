@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymize(t *testing.T) {
+	original, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	f := createTestFile(t)
+	_, err = f.Write(original)
+	require.NoError(t, err)
+
+	require.NoError(t, edf.Anonymize(f, edf.WithMidnightStartTime()))
+
+	anonymized, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Len(t, anonymized, len(original))
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	hdr := er.Header()
+	require.Equal(t, "X X X X", strings.TrimSpace(hdr.PatientID))
+	require.Equal(t, "Startdate 12-DEC-2024 X X X", strings.TrimSpace(hdr.RecordingID))
+
+	hour, minute, sec := hdr.StartTime.Clock()
+	require.Zero(t, hour+minute+sec)
+
+	// Everything after the identification and start time fields is untouched.
+	require.Equal(t, original[168:176], anonymized[168:176])
+	require.Equal(t, original[184:], anonymized[184:])
+
+	t.Run("EDFPlus", func(t *testing.T) {
+		startTime := time.Date(2002, time.March, 2, 10, 30, 0, 0, time.UTC)
+		f := writeTestFile(t, edf.Header{
+			Version:            edf.Version0,
+			PatientID:          "MCH-0234567 F 02-MAY-1951 Haagse_Harry",
+			RecordingID:        "Startdate 02-MAR-2002 PSG-1234/2002 NN Telemetry03",
+			StartTime:          startTime,
+			DataRecordDuration: time.Second,
+			SignalCount:        1,
+			Signals: []edf.SignalHeader{
+				{
+					Label:            "EEG",
+					PhysicalMin:      -1,
+					PhysicalMax:      1,
+					DigitalMin:       -2048,
+					DigitalMax:       2047,
+					SamplesPerRecord: 2,
+				},
+			},
+		}, [][][]float64{{{0.5, -0.5}}})
+
+		require.NoError(t, edf.Anonymize(f))
+
+		_, err := f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		er, err := edf.Open(f)
+		require.NoError(t, err)
+
+		hdr := er.Header()
+		require.True(t, startTime.Equal(hdr.StartTime))
+
+		patient, err := hdr.PatientInfo()
+		require.NoError(t, err)
+		require.Equal(t, edf.PatientInfo{}, patient)
+
+		recording, err := hdr.RecordingInfo()
+		require.NoError(t, err)
+		require.True(t, startTime.Equal(recording.StartDate))
+		require.Empty(t, recording.AdminCode)
+		require.Empty(t, recording.Technician)
+		require.Empty(t, recording.Equipment)
+
+		samples, err := er.ReadAll(0)
+		require.NoError(t, err)
+		require.InDeltaSlice(t, []float64{0.5, -0.5}, samples, 1e-3)
+	})
+}
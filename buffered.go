@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// BufferedWriter writes an EDF file to a sink that can't seek, such as an HTTP response
+// body or a pipe. As the header can only be finalized once the number of data records
+// is known, the whole file is staged, either in memory or in a temporary file, and only
+// written to the sink on Close. It supports all the methods of Writer.
+type BufferedWriter struct {
+	*Writer
+	dst   io.Writer
+	stage io.ReadWriteSeeker // Where the file is staged until Close
+	tmp   *os.File           // Temporary file backing stage, if any
+}
+
+// NewBufferedWriter creates a BufferedWriter that stages the file in memory. This is
+// the fastest option, but the whole file is held in memory until Close, so it suits
+// files of modest size.
+func NewBufferedWriter(dst io.Writer, hdr Header, opts ...WriterOption) (*BufferedWriter, error) {
+	stage := &memoryFile{}
+
+	ew, err := Create(stage, hdr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BufferedWriter{Writer: ew, dst: dst, stage: stage}, nil
+}
+
+// NewTempFileWriter creates a BufferedWriter that stages the file in a temporary file
+// in dir (or the default temporary directory if dir is empty), so memory use stays
+// constant regardless of the size of the file, at the cost of writing it to disk twice.
+// The temporary file is removed on Close.
+func NewTempFileWriter(dst io.Writer, hdr Header, dir string, opts ...WriterOption) (*BufferedWriter, error) {
+	tmp, err := os.CreateTemp(dir, "edf-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %w", err)
+	}
+
+	ew, err := Create(tmp, hdr, opts...)
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return &BufferedWriter{Writer: ew, dst: dst, stage: tmp, tmp: tmp}, nil
+}
+
+// Close finalizes the staged file and copies it to the sink.
+func (bw *BufferedWriter) Close() (err error) {
+	if bw.tmp != nil {
+		defer func() {
+			if closeErr := bw.tmp.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("error closing temporary file: %w", closeErr)
+			}
+			if removeErr := os.Remove(bw.tmp.Name()); removeErr != nil && err == nil {
+				err = fmt.Errorf("error removing temporary file: %w", removeErr)
+			}
+		}()
+	}
+
+	if err := bw.Writer.Close(); err != nil {
+		return err
+	}
+
+	if _, err := bw.stage.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error rewinding staged file: %w", err)
+	}
+
+	if _, err := io.Copy(bw.dst, bw.stage); err != nil {
+		return fmt.Errorf("error copying staged file: %w", err)
+	}
+
+	return nil
+}
+
+// memoryFile is an in-memory io.ReadWriteSeeker.
+type memoryFile struct {
+	b      []byte
+	offset int64
+}
+
+func (f *memoryFile) Read(p []byte) (int, error) {
+	if f.offset >= int64(len(f.b)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.b[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memoryFile) Write(p []byte) (int, error) {
+	if end := f.offset + int64(len(p)); end > int64(len(f.b)) {
+		f.b = append(f.b, make([]byte, end-int64(len(f.b)))...)
+	}
+
+	n := copy(f.b[f.offset:], p)
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memoryFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.b))
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	f.offset = offset
+	return offset, nil
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferedWriter(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -100,
+				PhysicalMax:      100,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		create func(dst io.Writer) (*edf.BufferedWriter, error)
+	}{
+		{
+			name: "Memory",
+			create: func(dst io.Writer) (*edf.BufferedWriter, error) {
+				return edf.NewBufferedWriter(dst, hdr)
+			},
+		},
+		{
+			name: "TempFile",
+			create: func(dst io.Writer) (*edf.BufferedWriter, error) {
+				return edf.NewTempFileWriter(dst, hdr, t.TempDir())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A plain bytes.Buffer can't seek back to finalize the header.
+			var buf bytes.Buffer
+
+			bw, err := tt.create(&buf)
+			require.NoError(t, err)
+
+			for i := 0; i < 3; i++ {
+				require.NoError(t, bw.WriteRecord([][]float64{{1, 2, 3, float64(i)}}))
+			}
+
+			// Nothing reaches the sink until the file is finalized.
+			require.Zero(t, buf.Len())
+			require.NoError(t, bw.Close())
+
+			er, err := edf.Open(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			require.Equal(t, 3, er.Header().DataRecords)
+
+			samples, err := er.ReadAll(0)
+			require.NoError(t, err)
+			require.Len(t, samples, 12)
+			require.InDelta(t, 2, samples[11], 0.1)
+		})
+	}
+
+	t.Run("TempFileRemoved", func(t *testing.T) {
+		dir := t.TempDir()
+
+		bw, err := edf.NewTempFileWriter(io.Discard, hdr, dir)
+		require.NoError(t, err)
+		require.NoError(t, bw.Close())
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"fmt"
+)
+
+// DetrendWindow selects the span of samples whose mean ReadDetrended subtracts.
+type DetrendWindow int
+
+const (
+	// DetrendRecord subtracts the mean of each data record from its samples. It follows
+	// a slowly drifting baseline, at the cost of small steps at record boundaries.
+	DetrendRecord DetrendWindow = iota
+	// DetrendSignal subtracts the mean of the entire signal. The baseline is constant,
+	// but the whole signal must be read once before the first samples are returned.
+	DetrendSignal
+)
+
+// ReadDetrended reads physical values from the signal, as Read does, with the DC offset
+// removed by subtracting the mean over the reader's detrend window (see
+// WithDetrendWindow). Means are always taken over complete records or the complete
+// signal, so the result doesn't depend on how reads are split up, but each new record
+// (DetrendRecord) or the first read (DetrendSignal) costs an extra pass over the data.
+// Transforms added with AddTransform are applied once, by Read, to the returned
+// samples; the means are taken over the calibrated values before any transforms, so a
+// transform that rescales or shifts the signal leaves its own offset in place.
+func (sr *SignalReader) ReadDetrended(data []float64) (int, error) {
+	record, sample := sr.currentRecord, sr.currentSample
+
+	n, err := sr.Read(data)
+
+	for i := 0; i < n; i++ {
+		mean, meanErr := sr.detrendMean(record)
+		if meanErr != nil {
+			return i, meanErr
+		}
+		data[i] -= mean
+
+		sample++
+		if sample >= sr.samplesPerRecord {
+			sample = 0
+			record++
+		}
+	}
+
+	return n, err
+}
+
+// detrendMean returns the mean to subtract from samples of the given record, computing
+// and caching it on first use. It is the mean of the untransformed physical values, as
+// rerunning the transforms over the re-read records would corrupt their state.
+func (sr *SignalReader) detrendMean(record int) (float64, error) {
+	if sr.detrendWindow == DetrendSignal {
+		record = -1
+	}
+	if sr.detrendRecord == record && sr.detrendValid {
+		return sr.detrendMeanValue, nil
+	}
+
+	first, last := record, record+1
+	if record < 0 {
+		first, last = 0, sr.dataRecords
+	}
+
+	block := make([]byte, sr.samplesPerRecord*sr.sampleWidth)
+	digital := make([]int32, sr.samplesPerRecord)
+	physical := make([]float64, sr.samplesPerRecord)
+
+	var sum float64
+	var count int
+	for i := first; i < last; i++ {
+		pos := int64(sr.hdr.HeaderBytes) + int64(i)*sr.recordSize + sr.signalOffset
+		if _, err := sr.file.readFullAt(block, pos); err != nil {
+			return 0, fmt.Errorf("error reading sample data: %w", err)
+		}
+
+		for j := range digital {
+			digital[j] = decodeSample(block[j*sr.sampleWidth:], sr.sampleWidth, sr.byteOrder)
+		}
+		sr.toPhysical(digital, physical)
+
+		for _, value := range physical {
+			sum += value
+		}
+		count += len(physical)
+	}
+
+	var mean float64
+	if count > 0 {
+		mean = sum / float64(count)
+	}
+
+	sr.detrendRecord, sr.detrendMeanValue, sr.detrendValid = record, mean, true
+
+	return mean, nil
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalReaderReadDetrended(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "ECG",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  100,
+			},
+		},
+	}
+
+	// A 5 Hz sine riding on a DC offset that steps up by 100 uV every record.
+	sine := func(i int) float64 {
+		return 100 * math.Sin(2*math.Pi*5*float64(i)/100)
+	}
+	offset := func(record int) float64 {
+		return 100 * float64(record+1)
+	}
+
+	records := make([][][]float64, 3)
+	for i := range records {
+		samples := make([]float64, 100)
+		for j := range samples {
+			samples[j] = offset(i) + sine(j)
+		}
+		records[i] = [][]float64{samples}
+	}
+
+	f := writeTestFile(t, hdr, records)
+
+	t.Run("Record", func(t *testing.T) {
+		er, err := edf.Open(f)
+		require.NoError(t, err)
+
+		sr, err := er.Signal(0)
+		require.NoError(t, err)
+
+		// Read in chunks that straddle record boundaries.
+		samples := make([]float64, 300)
+		for i := 0; i < len(samples); i += 70 {
+			end := i + 70
+			if end > len(samples) {
+				end = len(samples)
+			}
+			_, err := sr.ReadDetrended(samples[i:end])
+			require.NoError(t, err)
+		}
+
+		for i, sample := range samples {
+			require.InDelta(t, sine(i%100), sample, 0.5)
+		}
+	})
+
+	t.Run("Signal", func(t *testing.T) {
+		_, err := f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		er, err := edf.Open(f, edf.WithDetrendWindow(edf.DetrendSignal))
+		require.NoError(t, err)
+
+		sr, err := er.Signal(0)
+		require.NoError(t, err)
+
+		samples := make([]float64, 300)
+		n, err := sr.ReadDetrended(samples)
+		require.NoError(t, err)
+		require.Equal(t, 300, n)
+
+		// Only the mean offset of the whole signal is removed.
+		for i, sample := range samples {
+			require.InDelta(t, sine(i%100)+offset(i/100)-offset(1), sample, 0.5)
+		}
+	})
+
+	t.Run("Transforms", func(t *testing.T) {
+		_, err := f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		er, err := edf.Open(f)
+		require.NoError(t, err)
+
+		sr, err := er.Signal(0)
+		require.NoError(t, err)
+
+		// A stateful transform sees every sample exactly once, in order.
+		var calls, seen int
+		sr.AddTransform(func(samples []float64) {
+			calls++
+			seen += len(samples)
+		})
+
+		samples := make([]float64, 8)
+		n, err := sr.ReadDetrended(samples)
+		require.NoError(t, err)
+		require.Equal(t, 8, n)
+		require.Equal(t, 1, calls)
+		require.Equal(t, 8, seen)
+
+		for i, sample := range samples {
+			require.InDelta(t, sine(i), sample, 0.5)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// downsampleState is the state carried between calls to SignalReader.ReadDownsampled.
+type downsampleState struct {
+	factor int       // Decimation factor the state was built for
+	taps   []float64 // Low-pass filter taps applied before decimating
+	buf    []float64 // Buffered input samples, starting at input index base
+	base   int       // Input index of buf[0]
+	next   int       // Index of the next output sample
+	eof    bool      // Whether the input has been exhausted
+}
+
+// DefaultDownsampleTaps returns the low-pass filter ReadDownsampled uses for the given
+// decimation factor, unless overridden with SetDownsampleTaps: a Hamming windowed sinc
+// of 8*factor+1 taps with its cutoff at the decimated signal's Nyquist frequency
+// (half the new sample rate), normalized to unity gain at DC.
+func DefaultDownsampleTaps(factor int) []float64 {
+	return lowpassTaps(0.5/float64(factor), 8*factor+1)
+}
+
+// lowpassTaps returns a Hamming windowed sinc low-pass filter of the given length, with
+// its cutoff in cycles per input sample, normalized to unity gain at DC.
+func lowpassTaps(cutoff float64, length int) []float64 {
+	taps := make([]float64, length)
+	half := float64(len(taps)-1) / 2
+
+	var sum float64
+	for i := range taps {
+		x := float64(i) - half
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		window := 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(len(taps)-1))
+		taps[i] = sinc * window
+		sum += taps[i]
+	}
+
+	for i := range taps {
+		taps[i] /= sum
+	}
+
+	return taps
+}
+
+// SetDownsampleTaps overrides the low-pass filter applied by ReadDownsampled. The taps
+// are centered on each output sample, so a filter with an odd number of taps introduces
+// no delay. It must be called before the first call to ReadDownsampled.
+func (sr *SignalReader) SetDownsampleTaps(taps []float64) {
+	sr.downsampleTaps = taps
+}
+
+// ReadDownsampled reads physical values, decimated by the given factor, into data.
+// Unlike taking every factor'th sample, the signal is low-pass filtered first (see
+// DefaultDownsampleTaps and SetDownsampleTaps) so frequencies above the new Nyquist
+// frequency are attenuated rather than aliased into the output. Output sample k is
+// centered on input sample k*factor, and the signal is extended at either end by
+// repeating its first and last samples. The factor must stay the same across calls,
+// and calls shouldn't be interleaved with other reads from the same SignalReader.
+func (sr *SignalReader) ReadDownsampled(data []float64, factor int) (int, error) {
+	if factor < 1 {
+		return 0, fmt.Errorf("invalid decimation factor %d", factor)
+	}
+
+	ds := sr.downsample
+	if ds == nil {
+		taps := sr.downsampleTaps
+		if taps == nil {
+			taps = DefaultDownsampleTaps(factor)
+		}
+		if len(taps) == 0 {
+			return 0, fmt.Errorf("no downsample filter taps")
+		}
+		ds = &downsampleState{factor: factor, taps: taps}
+		sr.downsample = ds
+	} else if ds.factor != factor {
+		return 0, fmt.Errorf("decimation factor changed from %d to %d", ds.factor, factor)
+	}
+
+	half := (len(ds.taps) - 1) / 2
+	chunk := make([]float64, len(data)*factor+len(ds.taps))
+
+	var n int
+	for n < len(data) {
+		center := ds.next * factor
+
+		// Buffer enough input to cover the filter around the output sample.
+		for !ds.eof && ds.base+len(ds.buf) <= center+half {
+			read, err := sr.Read(chunk)
+			ds.buf = append(ds.buf, chunk[:read]...)
+			if errors.Is(err, io.EOF) {
+				ds.eof = true
+			} else if err != nil {
+				return n, err
+			}
+		}
+
+		if center >= ds.base+len(ds.buf) {
+			break
+		}
+
+		var sum float64
+		for j, tap := range ds.taps {
+			i := center + j - half - ds.base
+			if i < 0 {
+				i = 0
+			} else if i >= len(ds.buf) {
+				i = len(ds.buf) - 1
+			}
+			sum += tap * ds.buf[i]
+		}
+		data[n] = sum
+		n++
+		ds.next++
+
+		// Drop input no longer needed by the filter, keeping at least the last sample
+		// in case it is repeated to extend the end of the signal.
+		if drop := ds.next*factor - half - ds.base; drop > 0 {
+			if drop > len(ds.buf)-1 {
+				drop = len(ds.buf) - 1
+			}
+			ds.buf = ds.buf[drop:]
+			ds.base += drop
+		}
+	}
+
+	if n == 0 && len(data) > 0 {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
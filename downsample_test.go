@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalReaderReadDownsampled(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -250,
+				PhysicalMax:       250,
+				DigitalMin:        -32768,
+				DigitalMax:        32767,
+				SamplesPerRecord:  500,
+			},
+		},
+	}
+
+	// A 5 Hz sine we want to keep, plus a 180 Hz sine of equal amplitude that would alias
+	// to 20 Hz if the 500 Hz signal were naively decimated to 100 Hz.
+	wanted := func(t float64) float64 {
+		return 100 * math.Sin(2*math.Pi*5*t)
+	}
+	noise := func(t float64) float64 {
+		return 100 * math.Sin(2*math.Pi*180*t)
+	}
+
+	records := make([][][]float64, 4)
+	for i := range records {
+		samples := make([]float64, 500)
+		for j := range samples {
+			t := float64(i) + float64(j)/500
+			samples[j] = wanted(t) + noise(t)
+		}
+		records[i] = [][]float64{samples}
+	}
+
+	er, err := edf.Open(writeTestFile(t, hdr, records))
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	// Read in chunks that straddle record boundaries.
+	var downsampled []float64
+	chunk := make([]float64, 37)
+	for {
+		n, err := sr.ReadDownsampled(chunk, 5)
+		downsampled = append(downsampled, chunk[:n]...)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.Len(t, downsampled, 400)
+
+	// Away from the ends of the recording, only the 5 Hz component remains.
+	for k := 10; k < len(downsampled)-10; k++ {
+		require.InDelta(t, wanted(float64(k)/100), downsampled[k], 2.0, "sample %d", k)
+	}
+
+	// Naive striding keeps the aliased component at full strength.
+	all, err := er.ReadAll(0)
+	require.NoError(t, err)
+
+	var aliased float64
+	for k := 10; k < len(all)/5-10; k++ {
+		aliased = math.Max(aliased, math.Abs(all[k*5]-wanted(float64(k)/100)))
+	}
+	require.Greater(t, aliased, 50.0)
+
+	// The factor can't change part way through.
+	_, err = sr.ReadDownsampled(chunk, 2)
+	require.Error(t, err)
+
+	t.Run("Custom taps", func(t *testing.T) {
+		sr, err := er.Signal(0)
+		require.NoError(t, err)
+
+		// A single unity tap is naive striding.
+		sr.SetDownsampleTaps([]float64{1})
+
+		samples := make([]float64, 400)
+		n, err := sr.ReadDownsampled(samples, 5)
+		require.NoError(t, err)
+		require.Equal(t, 400, n)
+
+		for k := range samples {
+			require.Equal(t, all[k*5], samples[k])
+		}
+	})
+}
@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateSource identifies where the start date of a recording was taken from.
+type DateSource int
+
+const (
+	// DateSourceHeader means the date came from the 8 byte dd.mm.yy header field.
+	DateSourceHeader DateSource = iota
+	// DateSourceRecordingID means the date came from the EDF+ Startdate subfield of the
+	// recording identification, which carries an unambiguous four digit year.
+	DateSourceRecordingID
+)
+
+// parseEDFPlusDate parses an EDF+ dd-MMM-yyyy date (e.g. 02-AUG-1951). Month names are
+// matched case-insensitively.
+func parseEDFPlusDate(s string) (time.Time, error) {
+	return time.Parse("02-Jan-2006", s)
+}
+
+// recordingStartDate returns the date from the EDF+ Startdate subfield of a recording
+// identification, if present and known.
+func recordingStartDate(recordingID string) (time.Time, bool) {
+	fields := strings.Fields(recordingID)
+	if len(fields) < 2 || fields[0] != "Startdate" {
+		return time.Time{}, false
+	}
+
+	date, err := parseEDFPlusDate(fields[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return date, true
+}
+
+// PatientInfo holds the subfields of an EDF+ patient identification. Subfields given as
+// "X" (unknown) are left empty.
+type PatientInfo struct {
+	Code       string    // Hospital administration code
+	Sex        string    // "M" or "F"
+	BirthDate  time.Time // Date of birth
+	Name       string    // Name, with the underscores standing in for spaces replaced
+	Additional []string  // Any further subfields, as is
+}
+
+// PatientInfo parses the EDF+ patient identification, which packs the hospital code,
+// sex, birthdate (dd-MMM-yyyy), and name into space separated subfields, optionally
+// followed by additional subfields (e.g. "MCH-0234567 F 02-MAY-1951 Haagse_Harry").
+// It is an error if the field doesn't follow this layout, as in plain EDF files.
+func (h *Header) PatientInfo() (PatientInfo, error) {
+	fields := strings.Fields(h.PatientID)
+	if len(fields) < 4 {
+		return PatientInfo{}, fmt.Errorf("patient identification %q has %d subfields, expected at least 4", h.PatientID, len(fields))
+	}
+
+	// unknown returns the empty string in place of the "X" placeholder.
+	unknown := func(field string) string {
+		if field == "X" {
+			return ""
+		}
+		return field
+	}
+
+	info := PatientInfo{
+		Code: unknown(fields[0]),
+		Sex:  unknown(fields[1]),
+		Name: strings.ReplaceAll(unknown(fields[3]), "_", " "),
+	}
+
+	if info.Sex != "" && info.Sex != "M" && info.Sex != "F" {
+		return PatientInfo{}, fmt.Errorf("invalid sex %q", info.Sex)
+	}
+
+	if fields[2] != "X" {
+		birthDate, err := parseEDFPlusDate(fields[2])
+		if err != nil {
+			return PatientInfo{}, fmt.Errorf("invalid birthdate %q: %w", fields[2], err)
+		}
+		info.BirthDate = birthDate
+	}
+
+	if len(fields) > 4 {
+		info.Additional = fields[4:]
+	}
+
+	return info, nil
+}
+
+// PatientBirthdate returns the birthdate from the EDF+ patient identification (see
+// Header.PatientInfo). It returns false if the birthdate is unknown ("X") or the field
+// can't be parsed.
+func (er *Reader) PatientBirthdate() (time.Time, bool) {
+	info, err := er.hdr.PatientInfo()
+	if err != nil || info.BirthDate.IsZero() {
+		return time.Time{}, false
+	}
+
+	return info.BirthDate, true
+}
+
+// RecordingInfo holds the subfields of an EDF+ recording identification. Subfields
+// given as "X" (unknown) are left empty.
+type RecordingInfo struct {
+	StartDate  time.Time // Start of the recording
+	AdminCode  string    // Hospital administration code of the investigation
+	Technician string    // Code of the responsible technician or investigator
+	Equipment  string    // Code of the equipment used
+	Additional []string  // Any further subfields, as is
+}
+
+// RecordingInfo parses the EDF+ recording identification, which is the text
+// "Startdate" followed by the start date (dd-MMM-yyyy), administration code, technician
+// and equipment in space separated subfields, optionally followed by additional
+// subfields (e.g. "Startdate 02-MAR-2002 PSG-1234/2002 NN Telemetry03"). The start date
+// is combined with the time of day from the header's StartTime, its four digit year
+// taking precedence over the two digit year of the header's start date field. It is an
+// error if the field doesn't follow this layout, as in plain EDF files.
+func (h *Header) RecordingInfo() (RecordingInfo, error) {
+	fields := strings.Fields(h.RecordingID)
+	if len(fields) < 5 || fields[0] != "Startdate" {
+		return RecordingInfo{}, fmt.Errorf("recording identification %q is not of the form \"Startdate dd-MMM-yyyy code technician equipment\"", h.RecordingID)
+	}
+
+	// unknown returns the empty string in place of the "X" placeholder.
+	unknown := func(field string) string {
+		if field == "X" {
+			return ""
+		}
+		return field
+	}
+
+	info := RecordingInfo{
+		AdminCode:  unknown(fields[2]),
+		Technician: unknown(fields[3]),
+		Equipment:  unknown(fields[4]),
+	}
+
+	if fields[1] != "X" {
+		date, err := parseEDFPlusDate(fields[1])
+		if err != nil {
+			return RecordingInfo{}, fmt.Errorf("invalid start date %q: %w", fields[1], err)
+		}
+
+		hour, minute, sec := h.StartTime.Clock()
+		loc := h.StartTime.Location()
+		info.StartDate = time.Date(date.Year(), date.Month(), date.Day(), hour, minute, sec, h.StartTime.Nanosecond(), loc)
+	}
+
+	if len(fields) > 5 {
+		info.Additional = fields[5:]
+	}
+
+	return info, nil
+}
+
+// startDateUnknown reports whether an EDF+ recording identification declares the start
+// date unknown, with an "X" in place of the Startdate subfield's date.
+func startDateUnknown(recordingID string) bool {
+	fields := strings.Fields(recordingID)
+	return len(fields) >= 2 && fields[0] == "Startdate" && fields[1] == "X"
+}
+
+// The 44 byte reserved field is laid out as the continuity marker (e.g. "EDF+C") padded
+// to markerWidth bytes, a space, and then a vendor tag of up to vendorTagWidth bytes.
+const (
+	markerWidth    = 5
+	vendorTagWidth = 44 - markerWidth - 1
+)
+
+// splitReserved splits the reserved header field into the continuity marker and the
+// vendor tag. Only a field starting with a recognised continuity marker, or with a blank
+// marker, is split; anything else, such as the free text some tools write to the
+// reserved field of plain EDF files, is returned whole as the marker.
+func splitReserved(field string) (string, string) {
+	if len(field) <= markerWidth || field[markerWidth] != ' ' {
+		return strings.TrimSpace(field), ""
+	}
+
+	marker := strings.TrimSpace(field[:markerWidth])
+	if marker != "" && edfTypeOf(marker) == Plain {
+		return strings.TrimSpace(field), ""
+	}
+	return marker, strings.TrimSpace(field[markerWidth+1:])
+}
+
+// edfTypeOf returns the EDF type declared by a continuity marker. BDF+ files use the
+// same markers with a "BDF+" prefix.
+func edfTypeOf(marker string) EDFType {
+	switch marker {
+	case "EDF+C", "BDF+C":
+		return EDFPlusContinuous
+	case "EDF+D", "BDF+D":
+		return EDFPlusDiscontinuous
+	default:
+		return Plain
+	}
+}
+
+// continuityMarker returns the continuity marker declaring an EDF type, using the
+// "BDF+" prefix for BDF files. Plain files have no marker.
+func continuityMarker(t EDFType, version Version) string {
+	if t == Plain {
+		return ""
+	}
+
+	marker := t.String()
+	if version == VersionBDF {
+		marker = "BDF" + strings.TrimPrefix(marker, "EDF")
+	}
+	return marker
+}
+
+// joinReserved builds the reserved header field from the continuity marker and the
+// vendor tag.
+func joinReserved(marker, vendorTag string) (string, error) {
+	if vendorTag == "" {
+		return marker, nil
+	}
+	if len(marker) > markerWidth {
+		return "", fmt.Errorf("reserved field %q leaves no room for a vendor tag", marker)
+	}
+	if len(vendorTag) > vendorTagWidth {
+		return "", fmt.Errorf("vendor tag %q is longer than %d bytes", vendorTag, vendorTagWidth)
+	}
+	return fmt.Sprintf("%-*s %s", markerWidth, marker, vendorTag), nil
+}
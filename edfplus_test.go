@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderStartDateFromRecordingID(t *testing.T) {
+	tests := []struct {
+		name        string
+		startTime   time.Time
+		recordingID string
+		want        time.Time
+		wantSource  edf.DateSource
+	}{
+		{
+			name:        "Matching",
+			startTime:   time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+			recordingID: "Startdate 12-DEC-2024 X X X",
+			want:        time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+			wantSource:  edf.DateSourceRecordingID,
+		},
+		{
+			// The header field alone would read as 2060.
+			name:        "Ambiguous",
+			startTime:   time.Date(1960, 2, 1, 8, 0, 0, 0, time.UTC),
+			recordingID: "Startdate 01-FEB-1960 X X X",
+			want:        time.Date(1960, 2, 1, 8, 0, 0, 0, time.UTC),
+			wantSource:  edf.DateSourceRecordingID,
+		},
+		{
+			name:        "Conflicting",
+			startTime:   time.Date(1998, 2, 1, 8, 0, 0, 0, time.UTC),
+			recordingID: "Startdate 05-mar-1998 X X X",
+			want:        time.Date(1998, 3, 5, 8, 0, 0, 0, time.UTC),
+			wantSource:  edf.DateSourceRecordingID,
+		},
+		{
+			name:        "Unknown",
+			startTime:   time.Date(1998, 2, 1, 8, 0, 0, 0, time.UTC),
+			recordingID: "Startdate X X X X",
+			want:        time.Date(1998, 2, 1, 8, 0, 0, 0, time.UTC),
+			wantSource:  edf.DateSourceHeader,
+		},
+		{
+			// The header field alone would read as 2084 under the clipping rule.
+			name:        "BeforeClippingRange",
+			startTime:   time.Date(1984, 6, 1, 8, 0, 0, 0, time.UTC),
+			recordingID: "Startdate 01-JUN-1984 X X X",
+			want:        time.Date(1984, 6, 1, 8, 0, 0, 0, time.UTC),
+			wantSource:  edf.DateSourceRecordingID,
+		},
+		{
+			name:        "PlainEDFClippingRule",
+			startTime:   time.Date(2084, 6, 1, 8, 0, 0, 0, time.UTC),
+			recordingID: "Recording 1",
+			want:        time.Date(2084, 6, 1, 8, 0, 0, 0, time.UTC),
+			wantSource:  edf.DateSourceHeader,
+		},
+		{
+			name:        "PlainEDF",
+			startTime:   time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+			recordingID: "Recording 1",
+			want:        time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+			wantSource:  edf.DateSourceHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hdr := edf.Header{
+				Version:            edf.Version0,
+				RecordingID:        tt.recordingID,
+				StartTime:          tt.startTime,
+				DataRecordDuration: time.Second,
+				SignalCount:        1,
+				Signals: []edf.SignalHeader{
+					{
+						Label:            "Signal",
+						PhysicalMin:      -1,
+						PhysicalMax:      1,
+						DigitalMin:       -2048,
+						DigitalMax:       2047,
+						SamplesPerRecord: 1,
+					},
+				},
+			}
+
+			er, err := edf.Open(writeTestFile(t, hdr, nil))
+			require.NoError(t, err)
+
+			require.Equal(t, tt.want, er.Header().StartTime)
+			require.Equal(t, tt.wantSource, er.StartDateSource())
+		})
+	}
+}
+
+func TestReaderPatientBirthdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		patientID string
+		want      time.Time
+		wantOK    bool
+	}{
+		{
+			name:      "Known",
+			patientID: "MCH-0234567 F 02-MAY-1951 Haagse_Harry",
+			want:      time.Date(1951, 5, 2, 0, 0, 0, 0, time.UTC),
+			wantOK:    true,
+		},
+		{
+			name:      "Lowercase",
+			patientID: "MCH-0234567 F 02-may-1951 Haagse_Harry",
+			want:      time.Date(1951, 5, 2, 0, 0, 0, 0, time.UTC),
+			wantOK:    true,
+		},
+		{
+			name:      "Unknown",
+			patientID: "X X X X",
+		},
+		{
+			name:      "PlainEDF",
+			patientID: "Harry",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hdr := edf.Header{
+				Version:            edf.Version0,
+				PatientID:          tt.patientID,
+				StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+				DataRecordDuration: time.Second,
+				SignalCount:        1,
+				Signals: []edf.SignalHeader{
+					{
+						Label:            "Signal",
+						PhysicalMin:      -1,
+						PhysicalMax:      1,
+						DigitalMin:       -2048,
+						DigitalMax:       2047,
+						SamplesPerRecord: 1,
+					},
+				},
+			}
+
+			er, err := edf.Open(writeTestFile(t, hdr, nil))
+			require.NoError(t, err)
+
+			birthdate, ok := er.PatientBirthdate()
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, birthdate)
+		})
+	}
+}
+
+func TestHeaderPatientInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		patientID string
+		want      edf.PatientInfo
+		wantErr   bool
+	}{
+		{
+			name:      "Complete",
+			patientID: "MCH-0234567 F 02-MAY-1951 Haagse_Harry",
+			want: edf.PatientInfo{
+				Code:      "MCH-0234567",
+				Sex:       "F",
+				BirthDate: time.Date(1951, 5, 2, 0, 0, 0, 0, time.UTC),
+				Name:      "Haagse Harry",
+			},
+		},
+		{
+			name:      "Additional",
+			patientID: "MCH-0234567 M 02-may-1951 Haagse_Harry 180cm 75kg",
+			want: edf.PatientInfo{
+				Code:       "MCH-0234567",
+				Sex:        "M",
+				BirthDate:  time.Date(1951, 5, 2, 0, 0, 0, 0, time.UTC),
+				Name:       "Haagse Harry",
+				Additional: []string{"180cm", "75kg"},
+			},
+		},
+		{
+			name:      "Unknown",
+			patientID: "X X X X",
+		},
+		{
+			name:      "PlainEDF",
+			patientID: "Haagse Harry",
+			wantErr:   true,
+		},
+		{
+			name:      "InvalidBirthdate",
+			patientID: "MCH-0234567 F 1951-05-02 Haagse_Harry",
+			wantErr:   true,
+		},
+		{
+			name:      "InvalidSex",
+			patientID: "MCH-0234567 Q 02-MAY-1951 Haagse_Harry",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hdr := edf.Header{PatientID: tt.patientID}
+
+			info, err := hdr.PatientInfo()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, info)
+		})
+	}
+}
+
+func TestHeaderRecordingInfo(t *testing.T) {
+	startTime := time.Date(2002, 3, 2, 22, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		startTime   time.Time
+		recordingID string
+		want        edf.RecordingInfo
+		wantErr     bool
+	}{
+		{
+			name:        "Complete",
+			startTime:   startTime,
+			recordingID: "Startdate 02-MAR-2002 PSG-1234/2002 NN Telemetry03",
+			want: edf.RecordingInfo{
+				StartDate:  startTime,
+				AdminCode:  "PSG-1234/2002",
+				Technician: "NN",
+				Equipment:  "Telemetry03",
+			},
+		},
+		{
+			// The header's two digit year reads 1960 as 2060.
+			name:        "FourDigitYear",
+			startTime:   time.Date(2060, 2, 1, 8, 0, 0, 0, time.UTC),
+			recordingID: "Startdate 01-FEB-1960 X X X Sleep_lab",
+			want: edf.RecordingInfo{
+				StartDate:  time.Date(1960, 2, 1, 8, 0, 0, 0, time.UTC),
+				Additional: []string{"Sleep_lab"},
+			},
+		},
+		{
+			name:        "Unknown",
+			startTime:   startTime,
+			recordingID: "Startdate X X X X",
+		},
+		{
+			name:        "PlainEDF",
+			startTime:   startTime,
+			recordingID: "Recording 1",
+			wantErr:     true,
+		},
+		{
+			name:        "InvalidStartDate",
+			startTime:   startTime,
+			recordingID: "Startdate 2002-03-02 X X X",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hdr := edf.Header{StartTime: tt.startTime, RecordingID: tt.recordingID}
+
+			info, err := hdr.RecordingInfo()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, info)
+		})
+	}
+}
+
+func TestHeaderType(t *testing.T) {
+	tests := []struct {
+		name         string
+		version      edf.Version
+		edfType      edf.EDFType
+		reserved     string
+		wantReserved string
+		wantType     edf.EDFType
+	}{
+		{
+			name:     "Plain",
+			version:  edf.Version0,
+			wantType: edf.Plain,
+		},
+		{
+			name:         "Continuous",
+			version:      edf.Version0,
+			edfType:      edf.EDFPlusContinuous,
+			wantReserved: "EDF+C",
+			wantType:     edf.EDFPlusContinuous,
+		},
+		{
+			name:         "Discontinuous",
+			version:      edf.Version0,
+			edfType:      edf.EDFPlusDiscontinuous,
+			reserved:     "EDF+C",
+			wantReserved: "EDF+D",
+			wantType:     edf.EDFPlusDiscontinuous,
+		},
+		{
+			name:         "BDF",
+			version:      edf.VersionBDF,
+			edfType:      edf.EDFPlusContinuous,
+			wantReserved: "BDF+C",
+			wantType:     edf.EDFPlusContinuous,
+		},
+		{
+			// A marker without a type is kept as is.
+			name:         "Marker",
+			version:      edf.Version0,
+			reserved:     "EDF+D",
+			wantReserved: "EDF+D",
+			wantType:     edf.EDFPlusDiscontinuous,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hdr := edf.Header{
+				Version:            tt.version,
+				StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+				Reserved:           tt.reserved,
+				Type:               tt.edfType,
+				DataRecordDuration: time.Second,
+				SignalCount:        1,
+				Signals: []edf.SignalHeader{
+					{
+						Label:            "Signal",
+						PhysicalMin:      -1,
+						PhysicalMax:      1,
+						DigitalMin:       -2048,
+						DigitalMax:       2047,
+						SamplesPerRecord: 1,
+					},
+				},
+			}
+
+			er, err := edf.Open(writeTestFile(t, hdr, nil))
+			require.NoError(t, err)
+
+			require.Equal(t, tt.wantReserved, er.Header().Reserved)
+			require.Equal(t, tt.wantType, er.Header().Type)
+		})
+	}
+}
+
+func TestReaderEndTimeDiscontinuous(t *testing.T) {
+	hdr := annotatedHeader
+	hdr.Reserved = "EDF+D"
+
+	// Three one second records with gaps between them.
+	f := writeRawTestFile(t, hdr, [][]byte{
+		annotatedRecord("+0\x14\x14\x00"),
+		annotatedRecord("+5\x14\x14\x00"),
+		annotatedRecord("+10\x14\x14\x00"),
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	end, err := er.EndTime()
+	require.NoError(t, err)
+	require.Equal(t, hdr.StartTime.Add(11*time.Second), end)
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+// Package edftest provides helpers for testing code that produces EDF files.
+package edftest
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/OpenPSG/edf"
+)
+
+// AssertRoundTrip writes records to an in-memory EDF file described by hdr, reads the
+// file back and asserts that every sample matches the value written to within the
+// signal's quantization, i.e. one digital step. records is indexed by data record,
+// then signal, then sample. Samples must lie within each signal's physical range.
+// Annotation signals are not compared.
+func AssertRoundTrip(t testing.TB, hdr edf.Header, records [][][]float64) {
+	t.Helper()
+
+	f := &File{}
+
+	ew, err := edf.Create(f, hdr)
+	if err != nil {
+		t.Fatalf("error creating writer: %v", err)
+	}
+
+	for i, record := range records {
+		if err := ew.WriteRecord(record); err != nil {
+			t.Fatalf("error writing data record %d: %v", i, err)
+		}
+	}
+
+	if err := ew.Close(); err != nil {
+		t.Fatalf("error closing writer: %v", err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("error rewinding file: %v", err)
+	}
+
+	er, err := edf.Open(f)
+	if err != nil {
+		t.Fatalf("error opening written file: %v", err)
+	}
+
+	if got := er.Header().DataRecords; got != len(records) {
+		t.Fatalf("expected %d data records, got %d", len(records), got)
+	}
+
+	for i, signal := range hdr.Signals {
+		if signal.IsAnnotations() {
+			continue
+		}
+
+		sr, err := er.Signal(i)
+		if err != nil {
+			t.Fatalf("error opening signal %d: %v", i, err)
+		}
+
+		tolerance := math.Abs((signal.PhysicalMax-signal.PhysicalMin)/float64(signal.DigitalMax-signal.DigitalMin)) + 1e-9
+
+		samples := make([]float64, signal.SamplesPerRecord)
+		for j, record := range records {
+			if _, err := sr.Read(samples); err != nil && !errors.Is(err, io.EOF) {
+				t.Fatalf("error reading signal %d of data record %d: %v", i, j, err)
+			}
+
+			for k, want := range record[i] {
+				if math.Abs(samples[k]-want) > tolerance {
+					t.Errorf("signal %d data record %d sample %d: expected %v, got %v (tolerance %v)", i, j, k, want, samples[k], tolerance)
+				}
+			}
+		}
+	}
+}
+
+// File is an in-memory io.ReadWriteSeeker, suitable as the target of an edf.Writer.
+type File struct {
+	b      []byte
+	offset int64
+}
+
+// Bytes returns the contents of the file.
+func (f *File) Bytes() []byte {
+	return f.b
+}
+
+// Read reads from the file at the current offset.
+func (f *File) Read(p []byte) (int, error) {
+	if f.offset >= int64(len(f.b)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.b[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+// Write writes to the file at the current offset, growing it as needed.
+func (f *File) Write(p []byte) (int, error) {
+	if end := f.offset + int64(len(p)); end > int64(len(f.b)) {
+		f.b = append(f.b, make([]byte, end-int64(len(f.b)))...)
+	}
+
+	n := copy(f.b[f.offset:], p)
+	f.offset += int64(n)
+	return n, nil
+}
+
+// Seek sets the offset for the next Read or Write.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.b))
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	f.offset = offset
+	return offset, nil
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edftest_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/OpenPSG/edf/edftest"
+)
+
+func TestAssertRoundTrip(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 12, 12, 22, 0, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  100,
+			},
+			{
+				Label:             "Resp",
+				PhysicalDimension: "mV",
+				PhysicalMin:       -1,
+				PhysicalMax:       1,
+				DigitalMin:        -32768,
+				DigitalMax:        32767,
+				SamplesPerRecord:  10,
+			},
+		},
+	}
+
+	records := make([][][]float64, 3)
+	for i := range records {
+		eeg := make([]float64, 100)
+		for j := range eeg {
+			eeg[j] = 400 * math.Sin(2*math.Pi*float64(i*100+j)/50)
+		}
+
+		resp := make([]float64, 10)
+		for j := range resp {
+			resp[j] = math.Cos(2 * math.Pi * float64(i*10+j) / 30)
+		}
+
+		records[i] = [][]float64{eeg, resp}
+	}
+
+	edftest.AssertRoundTrip(t, hdr, records)
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrUnknownRecordCount is returned when an operation needs the number of data
+	// records but the header declares it as unknown (-1), as in a file that was never
+	// finalized.
+	ErrUnknownRecordCount = errors.New("unknown number of data records")
+	// ErrShortHeader is returned when a file ends before the end of its header. It
+	// wraps io.ErrUnexpectedEOF.
+	ErrShortHeader = fmt.Errorf("header is truncated: %w", io.ErrUnexpectedEOF)
+	// ErrTruncatedData is returned when a file ends before the end of a data record
+	// its header declares. It wraps io.ErrUnexpectedEOF.
+	ErrTruncatedData = fmt.Errorf("data record is truncated: %w", io.ErrUnexpectedEOF)
+	// ErrInvalidDate is returned when the start date or time of the header can't be
+	// parsed.
+	ErrInvalidDate = errors.New("invalid date")
+	// ErrSignalIndexOutOfRange is returned when a signal index doesn't refer to one of
+	// the signals of a file.
+	ErrSignalIndexOutOfRange = errors.New("signal index out of range")
+)
+
+// FieldError describes a failure to read or parse a header field.
+type FieldError struct {
+	Field  string // Name of the field, e.g. "StartDate" or "Signal[1].PhysicalMin"
+	Offset int    // Byte offset of the field from the start of the file
+	Err    error  // Underlying error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s at byte %d: %v", e.Field, e.Offset, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// multiError combines several errors into one.
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (m multiError) Unwrap() []error {
+	return m
+}
+
+// Is reports whether any of the combined errors matches target. Go releases before
+// 1.20 don't unwrap errors holding several, so errors.Is relies on this instead.
+func (m multiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the combined errors that matches target, as errors.As does.
+func (m multiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestFile creates an empty temporary file that is closed when the test completes.
+func createTestFile(t *testing.T) *os.File {
+	f, err := os.OpenFile(filepath.Join(t.TempDir(), "test.edf"), os.O_RDWR|os.O_CREATE, 0o644)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	return f
+}
+
+// writeTestFile writes the given data records to a temporary EDF file and returns it,
+// rewound to the start.
+func writeTestFile(t *testing.T, hdr edf.Header, records [][][]float64) *os.File {
+	f := createTestFile(t)
+
+	ew, err := edf.Create(f, hdr)
+	require.NoError(t, err)
+
+	for _, record := range records {
+		require.NoError(t, ew.WriteRecord(record))
+	}
+
+	require.NoError(t, ew.Close())
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	return f
+}
+
+// patchTestFile overwrites the bytes at the given offset of a test file with value,
+// leaving the file rewound to the start.
+func patchTestFile(t *testing.T, f *os.File, offset int64, value string) {
+	_, err := f.WriteAt([]byte(value), offset)
+	require.NoError(t, err)
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+}
+
+// writeRawTestFile writes a header followed by the given raw data records to a temporary
+// EDF file and returns it, rewound to the start.
+func writeRawTestFile(t *testing.T, hdr edf.Header, records [][]byte) *os.File {
+	f := writeTestFile(t, hdr, nil)
+
+	_, err := f.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+
+	for _, record := range records {
+		_, err := f.Write(record)
+		require.NoError(t, err)
+	}
+
+	patchTestFile(t, f, 236, fmt.Sprintf("%-8d", len(records)))
+
+	return f
+}
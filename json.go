@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// jsonVersionBDF stands in for VersionBDF in JSON, whose leading 0xFF byte isn't valid
+// UTF-8. It is the same version string read as Latin-1.
+const jsonVersionBDF = "ÿBIOSEMI"
+
+// headerJSON is the JSON representation of a Header.
+type headerJSON struct {
+	Version            string       `json:"version"`
+	PatientID          string       `json:"patientId"`
+	RecordingID        string       `json:"recordingId"`
+	StartTime          string       `json:"startTime"` // RFC 3339
+	HeaderBytes        int          `json:"headerBytes"`
+	Reserved           string       `json:"reserved"`
+	Type               string       `json:"type"`
+	VendorTag          string       `json:"vendorTag,omitempty"`
+	DataRecordDuration float64      `json:"dataRecordDuration"` // Seconds
+	DataRecords        int          `json:"dataRecords"`
+	SignalCount        int          `json:"signalCount"`
+	BytesPerSample     int          `json:"bytesPerSample,omitempty"`
+	TotalDuration      *float64     `json:"totalDuration,omitempty"` // Seconds, computed, omitted if the number of records is unknown
+	Signals            []signalJSON `json:"signals"`
+}
+
+// signalJSON is the JSON representation of a SignalHeader.
+type signalJSON struct {
+	Label             string  `json:"label"`
+	TransducerType    string  `json:"transducerType"`
+	PhysicalDimension string  `json:"physicalDimension"`
+	PhysicalMin       float64 `json:"physicalMin"`
+	PhysicalMax       float64 `json:"physicalMax"`
+	DigitalMin        int     `json:"digitalMin"`
+	DigitalMax        int     `json:"digitalMax"`
+	Prefiltering      string  `json:"prefiltering"`
+	SamplesPerRecord  int     `json:"samplesPerRecord"`
+	Reserved          string  `json:"reserved"`
+	SampleRate        float64 `json:"sampleRate,omitempty"` // Samples per second, computed
+}
+
+// MarshalJSON encodes the header as JSON, for indexing files without their sample data.
+// Along with every header field it includes the total duration of the recording and
+// the sample rate of each signal, computed from the header. The start time is encoded
+// in RFC 3339 format and durations in seconds. The computed fields are ignored by
+// UnmarshalJSON.
+func (h Header) MarshalJSON() ([]byte, error) {
+	version := string(h.Version)
+	if h.Version == VersionBDF {
+		version = jsonVersionBDF
+	}
+
+	hj := headerJSON{
+		Version:            version,
+		PatientID:          h.PatientID,
+		RecordingID:        h.RecordingID,
+		StartTime:          h.StartTime.Format(time.RFC3339),
+		HeaderBytes:        h.HeaderBytes,
+		Reserved:           h.Reserved,
+		Type:               h.Type.String(),
+		VendorTag:          h.VendorTag,
+		DataRecordDuration: h.DataRecordDuration.Seconds(),
+		DataRecords:        h.DataRecords,
+		SignalCount:        h.SignalCount,
+		BytesPerSample:     h.BytesPerSample,
+		Signals:            make([]signalJSON, len(h.Signals)),
+	}
+
+	if total, err := h.TotalDuration(); err == nil {
+		seconds := total.Seconds()
+		hj.TotalDuration = &seconds
+	}
+
+	for i, signal := range h.Signals {
+		hj.Signals[i] = signal.toJSON()
+		if !signal.IsAnnotations() {
+			hj.Signals[i].SampleRate = h.SampleRate(i)
+		}
+	}
+
+	return json.Marshal(hj)
+}
+
+// UnmarshalJSON decodes a header encoded by MarshalJSON.
+func (h *Header) UnmarshalJSON(b []byte) error {
+	var hj headerJSON
+	if err := json.Unmarshal(b, &hj); err != nil {
+		return err
+	}
+
+	version := Version(hj.Version)
+	if hj.Version == jsonVersionBDF {
+		version = VersionBDF
+	}
+
+	startTime, err := time.Parse(time.RFC3339, hj.StartTime)
+	if err != nil {
+		return fmt.Errorf("invalid start time: %w", err)
+	}
+
+	var edfType EDFType
+	switch hj.Type {
+	case "", Plain.String():
+		edfType = Plain
+	case EDFPlusContinuous.String():
+		edfType = EDFPlusContinuous
+	case EDFPlusDiscontinuous.String():
+		edfType = EDFPlusDiscontinuous
+	default:
+		return fmt.Errorf("invalid type %q", hj.Type)
+	}
+
+	*h = Header{
+		Version:            version,
+		PatientID:          hj.PatientID,
+		RecordingID:        hj.RecordingID,
+		StartTime:          startTime,
+		HeaderBytes:        hj.HeaderBytes,
+		Reserved:           hj.Reserved,
+		Type:               edfType,
+		VendorTag:          hj.VendorTag,
+		DataRecordDuration: time.Duration(math.Round(hj.DataRecordDuration * float64(time.Second))),
+		DataRecords:        hj.DataRecords,
+		SignalCount:        hj.SignalCount,
+		BytesPerSample:     hj.BytesPerSample,
+		Signals:            make([]SignalHeader, len(hj.Signals)),
+	}
+
+	for i, sj := range hj.Signals {
+		h.Signals[i] = sj.toSignalHeader()
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the signal header as JSON. Unlike when encoded as part of a
+// Header, the sample rate isn't included, as it depends on the data record duration.
+func (s SignalHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.toJSON())
+}
+
+// UnmarshalJSON decodes a signal header encoded by MarshalJSON.
+func (s *SignalHeader) UnmarshalJSON(b []byte) error {
+	var sj signalJSON
+	if err := json.Unmarshal(b, &sj); err != nil {
+		return err
+	}
+
+	*s = sj.toSignalHeader()
+	return nil
+}
+
+func (s SignalHeader) toJSON() signalJSON {
+	return signalJSON{
+		Label:             s.Label,
+		TransducerType:    s.TransducerType,
+		PhysicalDimension: s.PhysicalDimension,
+		PhysicalMin:       s.PhysicalMin,
+		PhysicalMax:       s.PhysicalMax,
+		DigitalMin:        s.DigitalMin,
+		DigitalMax:        s.DigitalMax,
+		Prefiltering:      s.Prefiltering,
+		SamplesPerRecord:  s.SamplesPerRecord,
+		Reserved:          s.Reserved,
+	}
+}
+
+func (sj signalJSON) toSignalHeader() SignalHeader {
+	return SignalHeader{
+		Label:             sj.Label,
+		TransducerType:    sj.TransducerType,
+		PhysicalDimension: sj.PhysicalDimension,
+		PhysicalMin:       sj.PhysicalMin,
+		PhysicalMax:       sj.PhysicalMax,
+		DigitalMin:        sj.DigitalMin,
+		DigitalMax:        sj.DigitalMax,
+		Prefiltering:      sj.Prefiltering,
+		SamplesPerRecord:  sj.SamplesPerRecord,
+		Reserved:          sj.Reserved,
+	}
+}
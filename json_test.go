@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderJSON(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	hdr := er.Header()
+
+	b, err := json.Marshal(hdr)
+	require.NoError(t, err)
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(b, &fields))
+
+	require.Equal(t, hdr.StartTime.Format("2006-01-02T15:04:05Z07:00"), fields["startTime"])
+	require.Equal(t, "EDF", fields["type"])
+	require.Equal(t, 40*hdr.DataRecordDuration.Seconds(), fields["totalDuration"])
+
+	signals := fields["signals"].([]any)
+	require.Len(t, signals, 4)
+	flow := signals[0].(map[string]any)
+	require.Equal(t, "Flow.40ms", flow["label"])
+	require.Equal(t, 1500/hdr.DataRecordDuration.Seconds(), flow["sampleRate"])
+
+	var decoded edf.Header
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.True(t, hdr.StartTime.Equal(decoded.StartTime))
+	decoded.StartTime = hdr.StartTime
+	require.Equal(t, hdr, decoded)
+
+	t.Run("BDF", func(t *testing.T) {
+		hdr := hdr
+		hdr.Version = edf.VersionBDF
+		hdr.Type = edf.EDFPlusDiscontinuous
+
+		b, err := json.Marshal(hdr)
+		require.NoError(t, err)
+		require.Contains(t, string(b), `"version":"ÿBIOSEMI"`)
+		require.Contains(t, string(b), `"type":"EDF+D"`)
+
+		var decoded edf.Header
+		require.NoError(t, json.Unmarshal(b, &decoded))
+		require.Equal(t, edf.VersionBDF, decoded.Version)
+		require.Equal(t, edf.EDFPlusDiscontinuous, decoded.Type)
+	})
+
+	t.Run("Signal", func(t *testing.T) {
+		b, err := json.Marshal(hdr.Signals[1])
+		require.NoError(t, err)
+		require.NotContains(t, string(b), "sampleRate")
+
+		var decoded edf.SignalHeader
+		require.NoError(t, json.Unmarshal(b, &decoded))
+		require.Equal(t, hdr.Signals[1], decoded)
+	})
+}
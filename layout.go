@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"fmt"
+)
+
+// headerField describes a fixed-width ASCII field of the EDF header.
+type headerField struct {
+	name  string
+	width int
+}
+
+// fixedHeaderFields are the fields of the fixed 256 byte header, in file order.
+var fixedHeaderFields = []headerField{
+	{"Version", 8},
+	{"PatientID", 80},
+	{"RecordingID", 80},
+	{"StartDate", 8},
+	{"StartTime", 8},
+	{"HeaderBytes", 8},
+	{"Reserved", 44},
+	{"DataRecords", 8},
+	{"Duration", 8},
+	{"SignalCount", 4},
+}
+
+// signalHeaderFields are the per-signal fields that follow the fixed header, in file
+// order. Each field is repeated once for every signal before the next field begins.
+var signalHeaderFields = []headerField{
+	{"Label", 16},
+	{"TransducerType", 80},
+	{"PhysicalDimension", 8},
+	{"PhysicalMin", 8},
+	{"PhysicalMax", 8},
+	{"DigitalMin", 8},
+	{"DigitalMax", 8},
+	{"Prefiltering", 80},
+	{"SamplesPerRecord", 8},
+	{"Reserved", 32},
+}
+
+// headerLayout returns the byte offset mandated by the standard for every header field
+// of a file with the given number of signals. Signal fields are named "Signal[i].Field".
+func headerLayout(signalCount int) map[string]int {
+	layout := make(map[string]int)
+
+	var offset int
+	for _, field := range fixedHeaderFields {
+		layout[field.name] = offset
+		offset += field.width
+	}
+
+	for _, field := range signalHeaderFields {
+		for i := 0; i < signalCount; i++ {
+			layout[fmt.Sprintf("Signal[%d].%s", i, field.name)] = offset
+			offset += field.width
+		}
+	}
+
+	return layout
+}
+
+// splitFixedHeader slices the fixed header fields out of b at their mandated offsets,
+// keyed by field name. If b is too short to hold every field, the error names the
+// first field that is incomplete.
+func splitFixedHeader(b []byte) (map[string][]byte, error) {
+	fields := make(map[string][]byte, len(fixedHeaderFields))
+
+	var offset int
+	for _, field := range fixedHeaderFields {
+		value, err := sliceHeaderField(b, 0, offset, field, field.name)
+		if err != nil {
+			return nil, err
+		}
+		fields[field.name] = value
+		offset += field.width
+	}
+
+	return fields, nil
+}
+
+// splitSignalHeaders slices the per-signal header fields out of b, the signal header
+// block that follows the fixed header, returning the fields of each signal keyed by
+// field name. If b is too short, the error names the first incomplete field and signal.
+func splitSignalHeaders(b []byte, signalCount int) ([]map[string][]byte, error) {
+	signals := make([]map[string][]byte, signalCount)
+	for i := range signals {
+		signals[i] = make(map[string][]byte, len(signalHeaderFields))
+	}
+
+	var offset int
+	for _, field := range signalHeaderFields {
+		for i := 0; i < signalCount; i++ {
+			value, err := sliceHeaderField(b, 256, offset, field, fmt.Sprintf("Signal[%d].%s", i, field.name))
+			if err != nil {
+				return nil, err
+			}
+			signals[i][field.name] = value
+			offset += field.width
+		}
+	}
+
+	return signals, nil
+}
+
+// sliceHeaderField returns the bytes of a field at offset within b, with bounds checking.
+// base is the file offset of b, used to report where a truncated field starts.
+func sliceHeaderField(b []byte, base, offset int, field headerField, name string) ([]byte, error) {
+	if offset+field.width > len(b) {
+		return nil, &FieldError{
+			Field:  name,
+			Offset: base + offset,
+			Err:    fmt.Errorf("have %d of %d bytes: %w", clampFieldBytes(len(b)-offset, field.width), field.width, ErrShortHeader),
+		}
+	}
+
+	return b[offset : offset+field.width], nil
+}
+
+// clampFieldBytes returns how many bytes of a field of the given width are present.
+func clampFieldBytes(available, width int) int {
+	if available < 0 {
+		return 0
+	}
+	if available > width {
+		return width
+	}
+	return available
+}
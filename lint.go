@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"fmt"
+)
+
+// Lint checks the file for problems that don't prevent it from being read, but which
+// indicate that it doesn't conform to the EDF/EDF+ standard. One error is returned
+// for each problem found.
+func (er *Reader) Lint() []error {
+	var problems []error
+
+	problems = append(problems, lintHeaderBytes(er.hdr)...)
+	problems = append(problems, lintDuplicateLabels(er.hdr)...)
+	problems = append(problems, er.lintTrailingBytes()...)
+
+	return problems
+}
+
+// lintHeaderBytes reports a declared header size that disagrees with the size implied
+// by the number of signals.
+func lintHeaderBytes(hdr *Header) []error {
+	if expected := 256 + hdr.SignalCount*256; hdr.HeaderBytes != expected {
+		return []error{fmt.Errorf("header declares %d bytes, expected %d for %d signals", hdr.HeaderBytes, expected, hdr.SignalCount)}
+	}
+	return nil
+}
+
+// lintDuplicateLabels reports data signals that share a label, which EDF+ forbids.
+// Annotation signals are exempt as a file may contain several of them.
+func lintDuplicateLabels(hdr *Header) []error {
+	var labels []string
+	indices := make(map[string][]int)
+	for i, signal := range hdr.Signals {
+		if signal.IsAnnotations() {
+			continue
+		}
+		if _, ok := indices[signal.Label]; !ok {
+			labels = append(labels, signal.Label)
+		}
+		indices[signal.Label] = append(indices[signal.Label], i)
+	}
+
+	var problems []error
+	for _, label := range labels {
+		if len(indices[label]) > 1 {
+			problems = append(problems, fmt.Errorf("duplicate signal label %q at indices %v", label, indices[label]))
+		}
+	}
+
+	return problems
+}
+
+// lintTrailingBytes reports bytes following the last complete data record, such as
+// padding or a trailing newline added by some tools.
+func (er *Reader) lintTrailingBytes() []error {
+	size, err := er.file.size()
+	if err != nil {
+		return []error{fmt.Errorf("error seeking to end of file: %w", err)}
+	}
+
+	if _, leftover := er.hdr.recordsInFile(size, er.sampleWidth); leftover > 0 {
+		return []error{fmt.Errorf("%d trailing bytes after the last complete data record", leftover)}
+	}
+	return nil
+}
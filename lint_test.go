@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintDuplicateLabels(t *testing.T) {
+	signal := edf.SignalHeader{
+		Label:             "EEG Fpz-Cz",
+		PhysicalDimension: "uV",
+		PhysicalMin:       -500,
+		PhysicalMax:       500,
+		DigitalMin:        -2048,
+		DigitalMax:        2047,
+		SamplesPerRecord:  256,
+	}
+
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals:            []edf.SignalHeader{signal, signal},
+	}
+
+	f := writeTestFile(t, hdr, nil)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	problems := er.Lint()
+	require.Len(t, problems, 1)
+	require.ErrorContains(t, problems[0], `duplicate signal label "EEG Fpz-Cz" at indices [0 1]`)
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	_, err = edf.Open(f, edf.WithStrict())
+	require.ErrorContains(t, err, "duplicate signal label")
+}
+
+func TestLintHeaderBytes(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -1,
+				PhysicalMax:      1,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 1,
+			},
+		},
+	}
+
+	f := writeTestFile(t, hdr, nil)
+	patchTestFile(t, f, 184, "1024    ")
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	require.Equal(t, int64(1024), er.DataOffset())
+
+	problems := er.Lint()
+	require.Len(t, problems, 1)
+	require.ErrorContains(t, problems[0], "header declares 1024 bytes, expected 512 for 1 signals")
+}
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SignalMeta summarizes the characteristics of a signal, including values derived
+// from the header such as sample rate and resolution.
+type SignalMeta struct {
+	Index       int     `json:"index"`                 // Index of the signal in the file
+	Label       string  `json:"label"`                 // Label of the signal (e.g., EEG Fpz-Cz)
+	Unit        string  `json:"unit"`                  // Physical dimension (e.g., uV, mV)
+	SampleRate  float64 `json:"sampleRate"`            // Samples per second
+	Resolution  float64 `json:"resolution"`            // Physical units per digital step
+	HighPass    float64 `json:"highPass,omitempty"`    // High-pass filter cutoff in Hz, 0 if none
+	LowPass     float64 `json:"lowPass,omitempty"`     // Low-pass filter cutoff in Hz, 0 if none
+	Notch       float64 `json:"notch,omitempty"`       // Notch filter frequency in Hz, 0 if none
+	Transducer  string  `json:"transducer,omitempty"`  // Type of transducer used
+	Annotations bool    `json:"annotations,omitempty"` // Whether this is an EDF+ annotations signal
+}
+
+// SignalMetadata returns a summary of every signal in the file. Annotation signals are
+// included but flagged, and have no sample rate or resolution.
+func (er *Reader) SignalMetadata() []SignalMeta {
+	meta := make([]SignalMeta, len(er.hdr.Signals))
+	for i, signal := range er.hdr.Signals {
+		meta[i] = SignalMeta{
+			Index:       i,
+			Label:       signal.Label,
+			Unit:        signal.PhysicalDimension,
+			Transducer:  signal.TransducerType,
+			Annotations: signal.IsAnnotations(),
+		}
+
+		if signal.IsAnnotations() {
+			continue
+		}
+
+		meta[i].SampleRate = er.hdr.SampleRate(i)
+		if signal.DigitalMax != signal.DigitalMin {
+			meta[i].Resolution = (signal.PhysicalMax - signal.PhysicalMin) / float64(signal.DigitalMax-signal.DigitalMin)
+		}
+		meta[i].HighPass, meta[i].LowPass, meta[i].Notch = parsePrefiltering(signal.Prefiltering)
+	}
+
+	return meta
+}
+
+// parsePrefiltering extracts the filter cutoffs from an EDF prefiltering field
+// (e.g. "HP:0.1Hz LP:75Hz N:50Hz"). Unrecognized or missing filters are returned as 0.
+func parsePrefiltering(s string) (highPass, lowPass, notch float64) {
+	for _, field := range strings.Fields(s) {
+		name, value, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+
+		value = strings.TrimSuffix(strings.TrimSuffix(value, "Hz"), "hz")
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToUpper(name) {
+		case "HP":
+			highPass = f
+		case "LP":
+			lowPass = f
+		case "N":
+			notch = f
+		}
+	}
+
+	return highPass, lowPass, notch
+}
+
+// HighestRateSignal returns the index and sample rate (in Hz) of the signal with the
+// highest sample rate, which is often used as the reference clock when aligning or
+// resampling the other signals. Annotation signals are ignored, and ties go to the
+// lowest index. If the file has no data signals, the index is -1.
+func (er *Reader) HighestRateSignal() (int, float64) {
+	index, rate := -1, 0.0
+	for i, signal := range er.hdr.Signals {
+		if signal.IsAnnotations() {
+			continue
+		}
+
+		if r := er.hdr.SampleRate(i); index < 0 || r > rate {
+			index, rate = i, r
+		}
+	}
+
+	return index, rate
+}
+
+// AreAligned reports whether two signals share the same sample rate, so that sample
+// i of one is simultaneous with sample i of the other and they can be indexed together.
+// Signals that aren't aligned can be related with AlignmentFactor.
+func (er *Reader) AreAligned(a, b int) bool {
+	if a < 0 || a >= len(er.hdr.Signals) || b < 0 || b >= len(er.hdr.Signals) {
+		return false
+	}
+	return er.hdr.Signals[a].SamplesPerRecord == er.hdr.Signals[b].SamplesPerRecord
+}
+
+// AlignmentFactor returns the smallest sample counts na and nb such that na samples of
+// signal a span the same time as nb samples of signal b, e.g. 8 and 1 for signals
+// sampled at 256 Hz and 32 Hz. Aligned signals give 1 and 1. Both are 0 if either
+// index is out of range or either signal has no samples.
+func (er *Reader) AlignmentFactor(a, b int) (int, int) {
+	if a < 0 || a >= len(er.hdr.Signals) || b < 0 || b >= len(er.hdr.Signals) {
+		return 0, 0
+	}
+
+	na, nb := er.hdr.Signals[a].SamplesPerRecord, er.hdr.Signals[b].SamplesPerRecord
+	if na <= 0 || nb <= 0 {
+		return 0, 0
+	}
+
+	divisor := gcd(na, nb)
+	return na / divisor, nb / divisor
+}
+
+// gcd returns the greatest common divisor of two positive integers.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// transducerCategories maps keywords found in transducer type descriptions to sensor
+// categories. Keywords are matched case-insensitively, in order.
+var transducerCategories = []struct {
+	keyword  string
+	category string
+}{
+	{"electrode", "electrode"},
+	{"thermistor", "thermistor"},
+	{"thermocouple", "thermocouple"},
+	{"strain gauge", "strain gauge"},
+	{"strain gage", "strain gauge"},
+	{"piezo", "piezoelectric"},
+	{"inductance", "inductance plethysmography"},
+	{"oximeter", "oximeter"},
+	{"pressure", "pressure transducer"},
+	{"microphone", "microphone"},
+	{"accelerometer", "accelerometer"},
+}
+
+// TransducerCategory classifies the signal's transducer type into a broad sensor
+// category, such as "electrode" for "AgAgCl electrode" or "thermistor" for
+// "Nasal thermistor". It returns an empty string if the transducer is not recognized.
+func (s SignalHeader) TransducerCategory() string {
+	transducer := strings.ToLower(s.TransducerType)
+	for _, entry := range transducerCategories {
+		if strings.Contains(transducer, entry.keyword) {
+			return entry.category
+		}
+	}
+	return ""
+}
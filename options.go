@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import "time"
+
+// ReaderOption configures optional behavior of a Reader.
+type ReaderOption func(*Reader)
+
+// WithMaxRecords limits reading to the first n data records, even if the header
+// declares more. This is useful for quickly previewing the start of a large file.
+// A value of zero or less means no limit.
+func WithMaxRecords(n int) ReaderOption {
+	return func(er *Reader) {
+		er.maxRecords = n
+	}
+}
+
+// WriterOption configures optional behavior of a Writer.
+type WriterOption func(*Writer)
+
+// WithUnknownPlaceholders fills an empty PatientID or RecordingID with the EDF+ "X"
+// placeholders for unknown subfields, as the EDF+ standard recommends, instead of
+// leaving the fields blank. The recording's Startdate subfield is taken from the
+// header's StartTime.
+func WithUnknownPlaceholders() WriterOption {
+	return func(ew *Writer) {
+		ew.unknownPlaceholders = true
+	}
+}
+
+// WithReadTimeout bounds how long each call to Open or SignalReader.Read may take.
+// Before each call a read deadline of timeout from now is set on the source, which must
+// implement SetReadDeadline(time.Time) error (as net.Conn and os.File do) for this to
+// have any effect; other sources are read without a deadline. Reads that exceed the
+// deadline fail with the source's timeout error, typically os.ErrDeadlineExceeded.
+func WithReadTimeout(timeout time.Duration) ReaderOption {
+	return func(er *Reader) {
+		er.timeout = timeout
+	}
+}
+
+// WithStrict makes Open reject files that violate the EDF/EDF+ standard in ways that
+// are otherwise tolerated (and reported by Lint or Validate), such as duplicate signal
+// labels or a header claiming more data records than the file holds.
+func WithStrict() ReaderOption {
+	return func(er *Reader) {
+		er.strict = true
+	}
+}
+
+// WithHeaderLayoutCheck makes the writer verify, as it writes the header, that every
+// field is written at the byte offset mandated by the standard, returning an error on
+// any mismatch. This guards against regressions in the header writer.
+func WithHeaderLayoutCheck() WriterOption {
+	return func(ew *Writer) {
+		ew.verifyHeaderLayout = true
+	}
+}
+
+// WithCanonicalDimensions rewrites common alternative spellings of physical dimensions
+// (e.g. "UV" or "microvolt") to their canonical form (e.g. "uV") when writing the
+// header. Unrecognized dimensions are written unchanged.
+func WithCanonicalDimensions() WriterOption {
+	return func(ew *Writer) {
+		ew.canonicalDimensions = true
+	}
+}
+
+// WithByteOrderDetection enables a heuristic for files whose samples may have been
+// written big-endian, in violation of the standard. On open, the first data record is
+// decoded both ways and the byte order yielding the most samples within each signal's
+// declared digital range is used, falling back to little-endian on a tie.
+//
+// The heuristic costs an extra record read and can be fooled by signals whose digital
+// range covers (nearly) all 16-bit values, since every sample is then in range either
+// way; such files are read as little-endian.
+func WithByteOrderDetection() ReaderOption {
+	return func(er *Reader) {
+		er.guessByteOrder = true
+	}
+}
+
+// WithMaxRecordSize sets the maximum size of a data record in bytes, in place of the
+// DefaultMaxRecordSize recommended by the EDF standard.
+func WithMaxRecordSize(size int) WriterOption {
+	return func(ew *Writer) {
+		ew.maxRecordSize = size
+	}
+}
+
+// WithOversizeRecordWarnings makes the writer accept data records larger than the
+// maximum record size, collecting a RecordSizeWarning for each (see Writer.Warnings)
+// rather than failing the write.
+func WithOversizeRecordWarnings() WriterOption {
+	return func(ew *Writer) {
+		ew.warnOversizeRecords = true
+	}
+}
+
+// WithCalibration overrides the conversion from physical to digital values for the
+// given signals, keyed by signal index, with digital = Gain*physical + Offset rather
+// than the conversion derived from the signal's physical and digital ranges. This
+// allows a device's own calibration to be reproduced exactly. The header's ranges
+// should still describe the same calibration, as readers derive it from them.
+func WithCalibration(calibrations map[int]Calibration) WriterOption {
+	return func(ew *Writer) {
+		ew.calibrations = calibrations
+	}
+}
+
+// WithNonFiniteFill makes the writer replace NaN and infinite physical samples, which
+// are otherwise rejected, with the given physical value (e.g. the signal's physical
+// minimum).
+func WithNonFiniteFill(value float64) WriterOption {
+	return func(ew *Writer) {
+		ew.fillNonFinite = true
+		ew.nonFiniteFill = value
+	}
+}
+
+// WithStrictRange makes the writer reject physical samples outside a signal's physical
+// range, which are otherwise clamped to the nearest end of the range. Values within
+// half a quantization step of the range still round into it and are accepted.
+func WithStrictRange() WriterOption {
+	return func(ew *Writer) {
+		ew.strictRange = true
+	}
+}
+
+// WithPadValue sets the physical value used to pad the final data record when the
+// writer is closed with a partial record buffered, in place of each signal's physical
+// minimum. The value should lie within every signal's physical range.
+func WithPadValue(value float64) WriterOption {
+	return func(ew *Writer) {
+		ew.padWithValue = true
+		ew.padValue = value
+	}
+}
+
+// WithClampedPhysicalValues clamps decoded physical values to each signal's declared
+// physical range. Digital values outside the declared digital range, as produced by
+// some buggy writers, would otherwise decode to physical values beyond the range.
+func WithClampedPhysicalValues() ReaderOption {
+	return func(er *Reader) {
+		er.clamp = true
+	}
+}
+
+// WithDetrendWindow sets the span of samples whose mean SignalReader.ReadDetrended
+// subtracts. The default is DetrendRecord.
+func WithDetrendWindow(window DetrendWindow) ReaderOption {
+	return func(er *Reader) {
+		er.detrendWindow = window
+	}
+}
+
+// WithCaseInsensitiveLabels makes Reader.SignalByLabel match labels regardless of case,
+// so e.g. "eeg fpz-cz" finds "EEG Fpz-Cz".
+func WithCaseInsensitiveLabels() ReaderOption {
+	return func(er *Reader) {
+		er.foldLabels = true
+	}
+}
+
+// AnonymizeOption configures optional behavior of Anonymize.
+type AnonymizeOption func(*anonymizeOptions)
+
+// anonymizeOptions holds the options of Anonymize.
+type anonymizeOptions struct {
+	midnight bool // Zero the start time of day
+}
+
+// WithMidnightStartTime makes Anonymize also set the recording's start time of day to
+// midnight, keeping its start date.
+func WithMidnightStartTime() AnonymizeOption {
+	return func(o *anonymizeOptions) {
+		o.midnight = true
+	}
+}
@@ -11,242 +11,931 @@ package edf
 
 import (
 	"bufio"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Reader reads EDF files.
 type Reader struct {
-	r   io.ReadSeeker
-	hdr *Header
+	r              io.ReadSeeker
+	file           *sharedFile // The file underlying r, shared with every SignalReader.
+	hdr            *Header
+	maxRecords     int              // Maximum number of data records to read, 0 for no limit.
+	timeout        time.Duration    // Read deadline applied to each operation, 0 for no deadline.
+	strict         bool             // Reject non-conformant files rather than tolerating them.
+	guessByteOrder bool             // Guess the byte order of samples rather than assuming little-endian.
+	byteOrder      binary.ByteOrder // Byte order of the samples.
+	duration       float64          // Data record duration in seconds, exactly as declared in the header.
+	dateSource     DateSource       // Where the start date was taken from.
+	clamp          bool             // Clamp physical values to the declared physical range.
+	detrendWindow  DetrendWindow    // Span of samples averaged by SignalReader.ReadDetrended.
+	sampleWidth    int              // Size of a sample in bytes, detected from the version: 2 for EDF, 3 for BDF.
+	foldLabels     bool             // Match labels case-insensitively in SignalByLabel.
+	frameRecord    int              // Next data record to be read by ReadFrame.
+	frameBuf       []byte           // Scratch space for the raw data record read by ReadFrame.
+	recordsCounted bool             // The header declared -1 data records, so they were counted from the file size.
 }
 
-// Open opens an EDF file for reading.
-func Open(r io.ReadSeeker) (*Reader, error) {
-	reader := bufio.NewReader(r)
+// Open opens an EDF file for reading. Reads seek r, so they are serialized between the
+// SignalReaders of the Reader; see OpenAt for a reader that can be read in parallel.
+//
+// If the header declares -1 data records, as left by a recording that was never
+// finalized, the number of complete data records is counted from the size of the file
+// and set in the header instead.
+func Open(r io.ReadSeeker, opts ...ReaderOption) (*Reader, error) {
+	return open(r, &sharedFile{r: r}, opts...)
+}
+
+// OpenAt opens an EDF file of size bytes for reading through an io.ReaderAt, such as an
+// os.File, a bytes.Reader, or one backed by HTTP range requests. Data is read with ReadAt
+// at computed offsets rather than by seeking, so the SignalReaders of the Reader don't
+// share a file position and can read in parallel.
+func OpenAt(r io.ReaderAt, size int64, opts ...ReaderOption) (*Reader, error) {
+	sr := io.NewSectionReader(r, 0, size)
+	return open(sr, &sharedFile{r: sr, ra: r}, opts...)
+}
+
+func open(r io.ReadSeeker, file *sharedFile, opts ...ReaderOption) (*Reader, error) {
+	er := &Reader{r: r, file: file}
+
+	for _, opt := range opts {
+		opt(er)
+	}
+
+	if err := setReadDeadline(r, er.timeout); err != nil {
+		return nil, fmt.Errorf("error setting read deadline: %w", err)
+	}
+
+	if err := er.readHeader(bufio.NewReader(r)); err != nil {
+		return nil, err
+	}
 
+	if er.hdr.DataRecords == -1 {
+		size, err := er.file.size()
+		if err != nil {
+			return nil, fmt.Errorf("error seeking to end of file: %w", err)
+		}
+
+		records, _ := er.hdr.recordsInFile(size, er.sampleWidth)
+		er.hdr.DataRecords = int(records)
+		er.recordsCounted = true
+	}
+
+	if er.strict {
+		if problems := lintDuplicateLabels(er.hdr); len(problems) > 0 {
+			return nil, problems[0]
+		}
+
+		// Catch headers claiming more data than the file holds, e.g. due to bogus sample
+		// counts, before reads produce garbage or fail deep into the file.
+		size, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, fmt.Errorf("error seeking to end of file: %w", err)
+		}
+		if claimed := int64(er.hdr.HeaderBytes) + int64(er.hdr.DataRecords)*er.hdr.recordSize(er.sampleWidth); er.hdr.DataRecords > 0 && claimed > size {
+			return nil, fmt.Errorf("header claims %d data records of %d bytes (%d bytes in total), but the file is only %d bytes",
+				er.hdr.DataRecords, er.hdr.recordSize(er.sampleWidth), claimed, size)
+		}
+	}
+
+	er.byteOrder = binary.LittleEndian
+
+	// BDF samples are always little-endian.
+	if er.guessByteOrder && er.sampleWidth == 2 && er.dataRecords() > 0 {
+		var err error
+		if er.byteOrder, err = er.detectByteOrder(); err != nil {
+			return nil, fmt.Errorf("error detecting byte order: %w", err)
+		}
+	}
+
+	return er, nil
+}
+
+// ParseHeader parses a header held in memory, e.g. read from a memory mapped file. The
+// slice must hold at least the fixed header and the signal headers it declares, and the
+// full HeaderBytes it declares; anything beyond the header, such as the data records of
+// a whole mapped file, is ignored. It is an ErrShortHeader error if the slice is too
+// short.
+func ParseHeader(b []byte) (*Header, error) {
+	er := &Reader{}
+
+	fixed := b
+	if len(fixed) > 256 {
+		fixed = fixed[:256]
+	}
+	if err := er.parseFixedHeader(fixed); err != nil {
+		return nil, err
+	}
+
+	if err := er.parseSignalHeaders(b[len(fixed):]); err != nil {
+		return nil, err
+	}
+
+	if len(b) < er.hdr.HeaderBytes {
+		return nil, fmt.Errorf("header declares %d bytes, but only %d were given: %w", er.hdr.HeaderBytes, len(b), ErrShortHeader)
+	}
+
+	return er.hdr, nil
+}
+
+// readHeader reads and parses the header from reader, which must be positioned at the
+// start of the file.
+func (er *Reader) readHeader(reader io.Reader) error {
 	b := make([]byte, 256)
-	if _, err := io.ReadFull(reader, b); err != nil {
-		return nil, fmt.Errorf("error reading header: %w", err)
+	n, err := io.ReadFull(reader, b)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error reading header: %w", err)
+	}
+
+	if err := er.parseFixedHeader(b[:n]); err != nil {
+		return err
+	}
+
+	// Read signal headers
+	b = make([]byte, er.hdr.SignalCount*256)
+	n, err = io.ReadFull(reader, b)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error reading signal headers: %w", err)
+	}
+
+	return er.parseSignalHeaders(b[:n])
+}
+
+// parseFixedHeader parses the fixed 256 byte header at the start of the file, setting
+// the reader's header, record duration and date source. The header's signals are left
+// to parseSignalHeaders.
+func (er *Reader) parseFixedHeader(b []byte) error {
+	fields, err := splitFixedHeader(b)
+	if err != nil {
+		return fmt.Errorf("error reading header: %w", err)
+	}
+
+	// fieldError attributes a parse failure to a fixed header field.
+	layout := headerLayout(0)
+	fieldError := func(name string, err error) error {
+		return &FieldError{Field: name, Offset: layout[name], Err: err}
 	}
 
 	// Parse fields based on EDF/EDF+ specifications
 	hdr := &Header{}
-	hdr.Version = Version(strings.TrimSpace(string(b[0:8])))
-	hdr.PatientID = strings.TrimSpace(string(b[8:88]))
-	hdr.RecordingID = strings.TrimSpace(string(b[88:168]))
-	dateStr := strings.TrimSpace(string(b[168:176]))
-	timeStr := strings.TrimSpace(string(b[176:184]))
-
-	// Parse start date and time
-	var err error
-	startDate, err := time.Parse("02.01.06", dateStr)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing start date: %w", err)
+	hdr.Version = Version(strings.TrimSpace(string(fields["Version"])))
+	hdr.PatientID = strings.TrimSpace(string(fields["PatientID"]))
+	hdr.RecordingID = strings.TrimSpace(string(fields["RecordingID"]))
+	dateStr := strings.TrimSpace(string(fields["StartDate"]))
+	timeStr := strings.TrimSpace(string(fields["StartTime"]))
+
+	// Parse start date and time, preferring the EDF+ Startdate subfield of the recording
+	// identification, which unlike the header field has an unambiguous four digit year,
+	// and falling back to the header field in any of headerDateLayouts.
+	startDate, ok := recordingStartDate(hdr.RecordingID)
+	if ok {
+		er.dateSource = DateSourceRecordingID
+	} else {
+		startDate, err = parseHeaderDate(dateStr)
+		if err != nil {
+			return fmt.Errorf("error parsing start date: %w", fieldError("StartDate", fmt.Errorf("%w: %v", ErrInvalidDate, err)))
+		}
 	}
 	startTime, err := time.Parse("15.04.05", timeStr)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing start time: %w", err)
+		return fmt.Errorf("error parsing start time: %w", fieldError("StartTime", fmt.Errorf("%w: %v", ErrInvalidDate, err)))
 	}
 	hdr.StartTime = time.Date(startDate.Year(), startDate.Month(), startDate.Day(),
 		startTime.Hour(), startTime.Minute(), startTime.Second(), 0, time.UTC)
 
 	// Continue reading header to get number of data records, duration of data records, etc.
-	headerBytes, err := strconv.Atoi(strings.TrimSpace(string(b[184:192])))
+	headerBytes, err := strconv.Atoi(strings.TrimSpace(string(fields["HeaderBytes"])))
 	if err != nil {
-		return nil, fmt.Errorf("error parsing header bytes: %w", err)
+		return fmt.Errorf("error parsing header bytes: %w", fieldError("HeaderBytes", err))
 	}
 	hdr.HeaderBytes = headerBytes
 
-	// Skip reserved bytes
-	hdr.Reserved = strings.TrimSpace(string(b[192:236]))
+	// The reserved field holds the EDF+ continuity marker and an optional vendor tag.
+	hdr.Reserved, hdr.VendorTag = splitReserved(string(fields["Reserved"]))
+	hdr.Type = edfTypeOf(hdr.Reserved)
 
-	numDataRecords, err := strconv.Atoi(strings.TrimSpace(string(b[236:244])))
+	numDataRecords, err := strconv.Atoi(strings.TrimSpace(string(fields["DataRecords"])))
 	if err != nil {
-		return nil, fmt.Errorf("error parsing number of data records: %w", err)
+		return fmt.Errorf("error parsing number of data records: %w", fieldError("DataRecords", err))
 	}
 	hdr.DataRecords = numDataRecords
 
-	hdr.DataRecordDuration, err = time.ParseDuration(fmt.Sprintf("%ss", strings.TrimSpace(string(b[244:252]))))
+	durationStr := strings.TrimSpace(string(fields["Duration"]))
+	hdr.DataRecordDuration, err = time.ParseDuration(fmt.Sprintf("%ss", durationStr))
 	if err != nil {
-		return nil, fmt.Errorf("error parsing data record duration: %w", err)
+		return fmt.Errorf("error parsing data record duration: %w", fieldError("Duration", err))
 	}
 
-	signalCount, err := strconv.Atoi(strings.TrimSpace(string(b[252:256])))
+	duration, err := strconv.ParseFloat(durationStr, 64)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing signal count: %w", err)
+		return fmt.Errorf("error parsing data record duration: %w", fieldError("Duration", err))
+	}
+
+	signalCount, err := strconv.Atoi(strings.TrimSpace(string(fields["SignalCount"])))
+	if err != nil {
+		return fmt.Errorf("error parsing signal count: %w", fieldError("SignalCount", err))
+	}
+	if signalCount < 0 {
+		return fmt.Errorf("invalid signal count: %w", fieldError("SignalCount", fmt.Errorf("negative value %d", signalCount)))
 	}
 	hdr.SignalCount = signalCount
 
-	// Read signal headers
+	er.hdr = hdr
+	er.duration = duration
+
+	return nil
+}
+
+// parseSignalHeaders parses the signal headers that follow the fixed header, completing
+// the header parsed by parseFixedHeader and setting the reader's sample width.
+func (er *Reader) parseSignalHeaders(b []byte) error {
+	hdr := er.hdr
+	signalCount := hdr.SignalCount
+
+	signalFields, err := splitSignalHeaders(b, signalCount)
+	if err != nil {
+		return fmt.Errorf("error reading signal headers: %w", err)
+	}
+
+	// Numeric fields that fail to parse are reported, rather than read as zero, as a
+	// zeroed calibration would silently turn every sample into garbage.
+	signalLayout := headerLayout(signalCount)
 	hdr.Signals = make([]SignalHeader, signalCount)
+	for i, fields := range signalFields {
+		signal := SignalHeader{
+			Label:             strings.TrimSpace(string(fields["Label"])),
+			TransducerType:    strings.TrimSpace(string(fields["TransducerType"])),
+			PhysicalDimension: strings.TrimSpace(string(fields["PhysicalDimension"])),
+			Prefiltering:      strings.TrimSpace(string(fields["Prefiltering"])),
+			Reserved:          strings.TrimSpace(string(fields["Reserved"])),
+		}
+
+		for _, field := range []struct {
+			name  string
+			value *float64
+		}{
+			{"PhysicalMin", &signal.PhysicalMin},
+			{"PhysicalMax", &signal.PhysicalMax},
+		} {
+			if *field.value, err = parseFloat(fields[field.name]); err != nil {
+				name := fmt.Sprintf("Signal[%d].%s", i, field.name)
+				return fmt.Errorf("error parsing signal %d header: %w", i, &FieldError{Field: name, Offset: signalLayout[name], Err: err})
+			}
+		}
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 16)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
+		for _, field := range []struct {
+			name  string
+			value *int
+		}{
+			{"DigitalMin", &signal.DigitalMin},
+			{"DigitalMax", &signal.DigitalMax},
+			{"SamplesPerRecord", &signal.SamplesPerRecord},
+		} {
+			if *field.value, err = parseInt(fields[field.name]); err != nil {
+				name := fmt.Sprintf("Signal[%d].%s", i, field.name)
+				return fmt.Errorf("error parsing signal %d header: %w", i, &FieldError{Field: name, Offset: signalLayout[name], Err: err})
+			}
 		}
 
-		hdr.Signals[i].Label = strings.TrimSpace(string(b))
+		hdr.Signals[i] = signal
+	}
+
+	// BDF files store 24-bit samples, EDF files 16-bit ones. All record offset and size
+	// math goes through the detected sample width.
+	er.sampleWidth = 2
+	if hdr.Version == VersionBDF {
+		er.sampleWidth = 3
 	}
+	hdr.BytesPerSample = er.sampleWidth
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 80)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
+	// The digital range must fit within the sample size.
+	for i, signal := range hdr.Signals {
+		if !fitsSample(signal.DigitalMin, er.sampleWidth) || !fitsSample(signal.DigitalMax, er.sampleWidth) {
+			return fmt.Errorf("signal %d digital range [%d, %d] exceeds %d-bit sample range", i, signal.DigitalMin, signal.DigitalMax, er.sampleWidth*8)
 		}
+	}
 
-		hdr.Signals[i].TransducerType = strings.TrimSpace(string(b))
+	// Guard against headers implying a file too large to address.
+	if recordSize := hdr.recordSize(er.sampleWidth); recordSize > 0 && int64(hdr.DataRecords) > (math.MaxInt64-int64(hdr.HeaderBytes))/recordSize {
+		return fmt.Errorf("header implies a file size that overflows a 64-bit offset")
 	}
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 8)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
-		}
+	return nil
+}
+
+// Header returns a copy of the file's header.
+func (er *Reader) Header() Header {
+	return *er.hdr
+}
+
+// StartDateSource reports where the start date of the recording was taken from.
+func (er *Reader) StartDateSource() DateSource {
+	return er.dateSource
+}
+
+// DataOffset returns the byte offset at which the data records begin, i.e. the size of
+// the header as declared in the file.
+func (er *Reader) DataOffset() int64 {
+	return int64(er.hdr.HeaderBytes)
+}
+
+// RecordSize returns the size of a data record in bytes, as determined by the signals'
+// samples per record and the sample width of the file format (2 bytes for EDF, 3 for
+// BDF).
+func (er *Reader) RecordSize() int64 {
+	return er.hdr.recordSize(er.sampleWidth)
+}
 
-		hdr.Signals[i].PhysicalDimension = strings.TrimSpace(string(b))
+// RecordDurationSeconds returns the duration of a data record in seconds, parsed directly
+// from the header. Unlike Header.DataRecordDuration, which is rounded to the nearest
+// nanosecond, this is the declared value itself and is better suited to sample rate math.
+func (er *Reader) RecordDurationSeconds() float64 {
+	return er.duration
+}
+
+// EndTime returns the wall-clock time at which the recording ends: the start time plus
+// the duration of all data records. For discontinuous (EDF+D) files it is the end of
+// the last data record, as given by its timekeeping annotation. If the header declares
+// -1 data records, the records counted from the size of the file are used.
+func (er *Reader) EndTime() (time.Time, error) {
+	if er.hdr.DataRecords < 0 {
+		return er.hdr.StartTime, ErrUnknownRecordCount
 	}
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 8)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
+	records := er.dataRecords()
+	end := time.Duration(records) * er.hdr.DataRecordDuration
+
+	if er.hdr.Type == EDFPlusDiscontinuous && records > 0 {
+		for i, signal := range er.hdr.Signals {
+			if !signal.IsAnnotations() {
+				continue
+			}
+
+			b := make([]byte, er.hdr.recordSize(er.sampleWidth))
+			if err := er.readRecord(records-1, b); err != nil {
+				return er.hdr.StartTime, err
+			}
+
+			onset, err := parseTimekeeping(er.hdr.signalBlock(b, i, er.sampleWidth))
+			if err != nil {
+				return er.hdr.StartTime, fmt.Errorf("error parsing annotations in record %d: %w", records-1, err)
+			}
+
+			end = onset + er.hdr.DataRecordDuration
+			break
 		}
+	}
 
-		hdr.Signals[i].PhysicalMin = parseFloat(b)
+	return er.hdr.StartTime.Add(end), nil
+}
+
+// detectByteOrder guesses the byte order of the samples by decoding the first data
+// record both ways and counting how many samples fall within each signal's declared
+// digital range. Big-endian is only chosen if it yields strictly more in-range samples.
+func (er *Reader) detectByteOrder() (binary.ByteOrder, error) {
+	b := make([]byte, er.hdr.recordSize(er.sampleWidth))
+	if err := er.readRecord(0, b); err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 8)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
+	var little, big int
+	for i, signal := range er.hdr.Signals {
+		if signal.IsAnnotations() {
+			continue
 		}
 
-		hdr.Signals[i].PhysicalMax = parseFloat(b)
+		block := er.hdr.signalBlock(b, i, er.sampleWidth)
+		for j := 0; j < signal.SamplesPerRecord; j++ {
+			sample := block[j*2 : j*2+2]
+			if inDigitalRange(int16(binary.LittleEndian.Uint16(sample)), signal) {
+				little++
+			}
+			if inDigitalRange(int16(binary.BigEndian.Uint16(sample)), signal) {
+				big++
+			}
+		}
 	}
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 8)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
-		}
+	if big > little {
+		return binary.BigEndian, nil
+	}
+	return binary.LittleEndian, nil
+}
 
-		hdr.Signals[i].DigitalMin = parseInt(b)
+func inDigitalRange(digital int16, signal SignalHeader) bool {
+	return int(digital) >= signal.DigitalMin && int(digital) <= signal.DigitalMax
+}
+
+// dataRecords returns the number of data records available for reading, taking
+// into account any configured limit.
+func (er *Reader) dataRecords() int {
+	if er.maxRecords > 0 && er.maxRecords < er.hdr.DataRecords {
+		return er.maxRecords
 	}
+	return er.hdr.DataRecords
+}
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 8)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
+// ForEachRecord reads the file one data record at a time, calling fn with the index of
+// each record and the physical values of every signal in it, indexed by signal. This
+// is the most flexible way to process a whole file in a single pass. The slices passed
+// to fn are reused for the next record, so they must be copied if needed after fn
+// returns. Annotation signals are passed as nil, and the raw values of BDF+ Status
+// signals are passed as is. Iteration stops at the first error returned by fn, which
+// is returned.
+func (er *Reader) ForEachRecord(fn func(index int, signals [][]float64) error) error {
+	signals := make([][]float64, len(er.hdr.Signals))
+	for i, signal := range er.hdr.Signals {
+		if !signal.IsAnnotations() {
+			signals[i] = make([]float64, signal.SamplesPerRecord)
 		}
-
-		hdr.Signals[i].DigitalMax = parseInt(b)
 	}
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 80)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
+	b := make([]byte, er.hdr.recordSize(er.sampleWidth))
+	for record := 0; record < er.dataRecords(); record++ {
+		if err := er.readRecord(record, b); err != nil {
+			return fmt.Errorf("record %d: %w", record, err)
 		}
 
-		hdr.Signals[i].Prefiltering = strings.TrimSpace(string(b))
+		er.decodeRecord(b, signals)
+
+		if err := fn(record, signals); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadFrame reads the next data record once and returns the physical values of every
+// signal in it, indexed by signal, each of SamplesPerRecord samples. This is far cheaper
+// than reading each signal separately when most signals are needed. Annotation signals
+// are returned as nil, and the raw values of BDF+ Status signals are returned as is.
+// Each call advances to the next record, independently of any SignalReader, and
+// io.EOF is returned after the last one.
+func (er *Reader) ReadFrame() ([][]float64, error) {
+	if er.frameRecord >= er.dataRecords() {
+		return nil, io.EOF
 	}
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 8)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
+	if er.frameBuf == nil {
+		er.frameBuf = make([]byte, er.hdr.recordSize(er.sampleWidth))
+	}
+	if err := er.readRecord(er.frameRecord, er.frameBuf); err != nil {
+		return nil, fmt.Errorf("record %d: %w", er.frameRecord, err)
+	}
+
+	signals := make([][]float64, len(er.hdr.Signals))
+	for i, signal := range er.hdr.Signals {
+		if !signal.IsAnnotations() {
+			signals[i] = make([]float64, signal.SamplesPerRecord)
 		}
+	}
+	er.decodeRecord(er.frameBuf, signals)
+	er.frameRecord++
 
-		hdr.Signals[i].SamplesPerRecord = parseInt(b)
+	return signals, nil
+}
+
+// ReadFrameContext is like ReadFrame, but returns ctx.Err() without reading anything if
+// ctx has been cancelled, so a loop over the records of a file can be cancelled.
+func (er *Reader) ReadFrameContext(ctx context.Context) ([][]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < signalCount; i++ {
-		b := make([]byte, 32)
-		if _, err := io.ReadFull(reader, b); err != nil {
-			return nil, fmt.Errorf("error reading signal headers: %w", err)
+	return er.ReadFrame()
+}
+
+// decodeRecord decodes a raw data record into the physical values of each signal,
+// leaving annotation signals alone and passing the raw values of Status signals as is.
+func (er *Reader) decodeRecord(b []byte, signals [][]float64) {
+	for i, signal := range er.hdr.Signals {
+		if signal.IsAnnotations() {
+			continue
+		}
+
+		block := er.hdr.signalBlock(b, i, er.sampleWidth)
+		if signal.IsStatus() {
+			for j := range signals[i] {
+				signals[i][j] = float64(decodeSample(block[j*er.sampleWidth:], er.sampleWidth, er.byteOrder))
+			}
+			continue
 		}
+		er.decodeSamples(block, signal, signals[i])
+	}
+}
 
-		hdr.Signals[i].Reserved = strings.TrimSpace(string(b))
+// TotalSamples returns the number of samples across all data signals of the file, which
+// is useful for progress reporting and capacity planning. Annotation signals are not
+// counted. If the header declares -1 data records, the records counted from the size of
+// the file are used.
+func (er *Reader) TotalSamples() int64 {
+	records := er.dataRecords()
+	if records < 0 {
+		return 0
 	}
 
-	return &Reader{
-		r:   r,
-		hdr: hdr,
-	}, nil
+	var total int64
+	for _, signal := range er.hdr.Signals {
+		if !signal.IsAnnotations() {
+			total += int64(records) * int64(signal.SamplesPerRecord)
+		}
+	}
+	return total
 }
 
-// SignalReader reads continuous signal data from an EDF file.
+// decodeSamples decodes a signal's block of a raw data record into physical values.
+func (er *Reader) decodeSamples(block []byte, signal SignalHeader, samples []float64) {
+	for i := range samples {
+		digitalValue := decodeSample(block[i*er.sampleWidth:], er.sampleWidth, er.byteOrder)
+		samples[i] = convertDigitalToPhysical(digitalValue, signal.DigitalMin, signal.DigitalMax, signal.PhysicalMin, signal.PhysicalMax)
+		if er.clamp {
+			samples[i] = clampPhysical(samples[i], signal)
+		}
+	}
+}
+
+// SignalReader reads continuous signal data from an EDF file. SignalReaders obtained
+// from the same Reader share its underlying file but may be used from different
+// goroutines at the same time, as their reads of the file don't interfere. A single
+// SignalReader must still only be used by one goroutine at a time.
 type SignalReader struct {
 	r                io.ReadSeeker
+	file             *sharedFile // The file underlying r, shared with the Reader
 	hdr              *Header
-	signalIndex      int // Index of the signal to read
-	currentRecord    int // Current record being processed
-	currentSample    int // Current sample in the record
-	recordSize       int // Total size of one data record
-	signalOffset     int // Byte offset of the signal in a record
-	samplesPerRecord int // Number of samples per record for the signal
+	signalIndex      int               // Index of the signal to read
+	dataRecords      int               // Number of data records available for reading
+	timeout          time.Duration     // Read deadline applied to each call to Read
+	currentRecord    int               // Current record being processed
+	currentSample    int               // Current sample in the record
+	recordSize       int64             // Total size of one data record
+	signalOffset     int64             // Byte offset of the signal in a record
+	samplesPerRecord int               // Number of samples per record for the signal
+	byteOrder        binary.ByteOrder  // Byte order of the samples
+	digital          []int32           // Scratch space for decoding digital values
+	sample           [3]byte           // Scratch space for reading a single sample
+	block            []byte            // Buffered blocks of samples, from record blockStart
+	blockStart       int               // Record of the first buffered block
+	blockRecords     int               // Number of records with buffered blocks
+	sampleWidth      int               // Size of a sample in bytes
+	clamp            bool              // Clamp physical values to the declared physical range
+	detrendWindow    DetrendWindow     // Span of samples averaged by ReadDetrended
+	detrendRecord    int               // Record the cached detrend mean belongs to, or -1 for the whole signal
+	detrendMeanValue float64           // Cached detrend mean
+	detrendValid     bool              // Whether the cached detrend mean is valid
+	streamErr        error             // Error that ended the last Stream, if any
+	transforms       []func([]float64) // Transforms applied by Read, in order
+	downsampleTaps   []float64         // Filter taps overriding the ReadDownsampled default
+	downsample       *downsampleState  // State carried between calls to ReadDownsampled
+	resample         *resampleState    // State carried between calls to ReadResampled
 }
 
 // Signal creates a new SignalReader for a specified signal index.
 func (er *Reader) Signal(signalIndex int) (*SignalReader, error) {
 	if signalIndex < 0 || signalIndex >= len(er.hdr.Signals) {
-		return nil, fmt.Errorf("signal index out of range")
-	}
-
-	signal := er.hdr.Signals[signalIndex]
-	recordSize := 0
-	signalOffset := 0
-	for i, sig := range er.hdr.Signals {
-		if i < signalIndex {
-			signalOffset += sig.SamplesPerRecord * 2
-		}
-		recordSize += sig.SamplesPerRecord * 2
+		return nil, fmt.Errorf("%w: %d", ErrSignalIndexOutOfRange, signalIndex)
 	}
 
 	return &SignalReader{
 		r:                er.r,
+		file:             er.file,
 		hdr:              er.hdr,
 		signalIndex:      signalIndex,
-		recordSize:       recordSize,
-		signalOffset:     signalOffset,
-		samplesPerRecord: signal.SamplesPerRecord,
+		dataRecords:      er.dataRecords(),
+		timeout:          er.timeout,
+		recordSize:       er.hdr.recordSize(er.sampleWidth),
+		signalOffset:     er.hdr.signalOffset(signalIndex, er.sampleWidth),
+		samplesPerRecord: er.hdr.Signals[signalIndex].SamplesPerRecord,
+		byteOrder:        er.byteOrder,
+		sampleWidth:      er.sampleWidth,
+		clamp:            er.clamp,
+		detrendWindow:    er.detrendWindow,
 	}, nil
 }
 
+// SignalByLabel creates a new SignalReader for the signal with the given label. Labels
+// are compared with surrounding spaces trimmed, and regardless of case if the reader was
+// opened with WithCaseInsensitiveLabels. It is an error if no signal, or more than one,
+// has the label.
+func (er *Reader) SignalByLabel(label string) (*SignalReader, error) {
+	indices := er.hdr.signalIndices(label, er.foldLabels)
+	switch len(indices) {
+	case 0:
+		return nil, fmt.Errorf("no signal labelled %q", label)
+	case 1:
+		return er.Signal(indices[0])
+	default:
+		return nil, fmt.Errorf("signal label %q is ambiguous, matching signals %v", label, indices)
+	}
+}
+
+// Labels returns the labels of the file's signals, in order.
+func (er *Reader) Labels() []string {
+	labels := make([]string, len(er.hdr.Signals))
+	for i, signal := range er.hdr.Signals {
+		labels[i] = signal.Label
+	}
+	return labels
+}
+
+// ReadAll reads all of a signal's samples as physical values.
+func (er *Reader) ReadAll(signalIndex int) ([]float64, error) {
+	sr, err := er.Signal(signalIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]float64, sr.dataRecords*sr.samplesPerRecord)
+	n, err := sr.Read(samples)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return samples[:n], nil
+}
+
+// ReadAllByLabel reads every data signal in full, returning the physical values keyed
+// by signal label. Annotation signals are excluded, and duplicate labels are an error.
+// The entire file is held in memory, at 8 bytes per sample, so for long recordings it
+// may be preferable to read only the signals needed with ReadAll or Signal.
+func (er *Reader) ReadAllByLabel() (map[string][]float64, error) {
+	if problems := lintDuplicateLabels(er.hdr); len(problems) > 0 {
+		return nil, problems[0]
+	}
+
+	signals := make(map[string][]float64)
+	for i, label := range er.Labels() {
+		if er.hdr.Signals[i].IsAnnotations() {
+			continue
+		}
+
+		samples, err := er.ReadAll(i)
+		if err != nil {
+			return nil, fmt.Errorf("error reading signal %q: %w", label, err)
+		}
+		signals[label] = samples
+	}
+
+	return signals, nil
+}
+
+// readRecord reads the raw bytes of a data record into b, which must be the size of
+// a complete data record.
+func (er *Reader) readRecord(record int, b []byte) error {
+	if err := setReadDeadline(er.r, er.timeout); err != nil {
+		return fmt.Errorf("error setting read deadline: %w", err)
+	}
+
+	pos := int64(er.hdr.HeaderBytes) + int64(record)*int64(len(b))
+	if _, err := er.file.readFullAt(b, pos); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = ErrTruncatedData
+		}
+		return fmt.Errorf("error reading data record: %w", err)
+	}
+
+	return nil
+}
+
 // Read reads data from the signal.
+//
+// Samples are converted to physical values using the signal's calibration, except
+// for BDF+ "Status" signals, whose raw digital values are returned unchanged as they
+// carry trigger bits rather than a measurement (see TriggerBits).
 func (sr *SignalReader) Read(data []float64) (int, error) {
-	buf := make([]byte, 2)
+	if cap(sr.digital) < len(data) {
+		sr.digital = make([]int32, len(data))
+	}
+	digital := sr.digital[:len(data)]
+
+	n, err := sr.ReadDigital(digital)
+	sr.toPhysical(digital[:n], data)
+
+	for _, transform := range sr.transforms {
+		transform(data[:n])
+	}
+
+	return n, err
+}
+
+// ReadContext is like Read, but checks ctx between data records, so a long read can be
+// cancelled. If ctx is cancelled, it returns the number of samples read so far, which
+// are left in data, along with ctx.Err(). The position of the next Read follows the
+// last sample returned.
+func (sr *SignalReader) ReadContext(ctx context.Context, data []float64) (int, error) {
+	var n int
+	for n < len(data) {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		// Read up to the end of the current record.
+		end := len(data)
+		if remaining := sr.samplesPerRecord - sr.currentSample; remaining > 0 && n+remaining < end {
+			end = n + remaining
+		}
+
+		read, err := sr.Read(data[n:end])
+		n += read
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// ReadAt reads physical values into data starting at the given sample index from the
+// start of the signal, without reading the samples before it. The position used by
+// Read is left untouched, so ReadAt can be used for random access (e.g. scrubbing in a
+// viewer) alongside sequential reads. Like Read, it returns io.EOF if the end of the
+// signal is reached, including when sampleIndex is at or past the end.
+func (sr *SignalReader) ReadAt(data []float64, sampleIndex int64) (int, error) {
+	if sampleIndex < 0 {
+		return 0, fmt.Errorf("negative sample index %d", sampleIndex)
+	}
+	if sampleIndex >= sr.NumSamples() {
+		return 0, io.EOF
+	}
+
+	record, sample := sr.currentRecord, sr.currentSample
+	defer func() {
+		sr.currentRecord, sr.currentSample = record, sample
+	}()
+
+	sr.currentRecord = int(sampleIndex / int64(sr.samplesPerRecord))
+	sr.currentSample = int(sampleIndex % int64(sr.samplesPerRecord))
+
+	return sr.Read(data)
+}
+
+// Seek sets the position of the next Read to offset samples, interpreted according to
+// whence as for io.Seeker: relative to the start of the signal, the current position,
+// or the end of the signal (its number of data records times its samples per record).
+// It returns the new position in samples from the start of the signal. Seeking past the
+// end is allowed, with reads then returning io.EOF; seeking before the start is an
+// error. Any state carried between calls to ReadDownsampled or ReadResampled is
+// discarded.
+func (sr *SignalReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = int64(sr.currentRecord)*int64(sr.samplesPerRecord) + int64(sr.currentSample) + offset
+	case io.SeekEnd:
+		pos = sr.NumSamples() + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+
+	if pos < 0 {
+		return 0, fmt.Errorf("negative position %d", pos)
+	}
+
+	if sr.samplesPerRecord > 0 {
+		sr.currentRecord = int(pos / int64(sr.samplesPerRecord))
+		sr.currentSample = int(pos % int64(sr.samplesPerRecord))
+	}
+	sr.downsample = nil
+	sr.resample = nil
+
+	return pos, nil
+}
+
+// TimeAt returns the time of the sample at the given index from the start of the
+// signal: the start time of the recording plus the onset of the sample's data record,
+// plus the sample's position within the record. In EDF+D files the record's onset is
+// read from its timekeeping annotation, as there may be gaps between records; otherwise
+// records are assumed to follow on from one another. The position used by Read is left
+// untouched.
+func (sr *SignalReader) TimeAt(sampleIndex int64) (time.Time, error) {
+	if sampleIndex < 0 {
+		return time.Time{}, fmt.Errorf("negative sample index %d", sampleIndex)
+	}
+	if sr.samplesPerRecord <= 0 {
+		return time.Time{}, fmt.Errorf("signal has no samples")
+	}
+
+	offset := sr.hdr.sampleOffset(sr.signalIndex, sampleIndex)
+	if sr.hdr.Type != EDFPlusDiscontinuous {
+		return sr.hdr.StartTime.Add(offset), nil
+	}
+
+	for i, signal := range sr.hdr.Signals {
+		if !signal.IsAnnotations() {
+			continue
+		}
+
+		record := sampleIndex / int64(sr.samplesPerRecord)
+		if record >= int64(sr.dataRecords) {
+			return time.Time{}, fmt.Errorf("sample %d is beyond the last data record", sampleIndex)
+		}
+
+		block := make([]byte, signal.SamplesPerRecord*sr.sampleWidth)
+		pos := int64(sr.hdr.HeaderBytes) + record*sr.recordSize + sr.hdr.signalOffset(i, sr.sampleWidth)
+		if _, err := sr.file.readFullAt(block, pos); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = ErrTruncatedData
+			}
+			return time.Time{}, fmt.Errorf("error reading annotations: %w", err)
+		}
+
+		onset, err := parseTimekeeping(block)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error parsing annotations in record %d: %w", record, err)
+		}
+
+		// Replace the record's nominal onset with the actual one.
+		offset += onset - time.Duration(record)*sr.hdr.DataRecordDuration
+		break
+	}
+
+	return sr.hdr.StartTime.Add(offset), nil
+}
+
+// AddTransform appends fn to the reader's transform pipeline. Transforms are applied in
+// the order they were added to the physical values of each block returned by Read (and
+// so by Stream and ReadDetrended), after calibration and any clamping. They operate on
+// one block at a time, whose size is set by the caller's buffer and may vary between
+// reads, so transforms that need context across samples must keep their own state.
+// ReadDigital and ReadBoth return untransformed values.
+func (sr *SignalReader) AddTransform(fn func(samples []float64)) {
+	sr.transforms = append(sr.transforms, fn)
+}
+
+// ReadBoth reads samples from the signal in a single pass, filling physical with the
+// calibrated values (as Read does) and digital with the raw values they were decoded
+// from (as ReadDigital does). The two slices must be of equal length.
+func (sr *SignalReader) ReadBoth(physical []float64, digital []int32) (int, error) {
+	if len(physical) != len(digital) {
+		return 0, fmt.Errorf("physical and digital buffers differ in length (%d != %d)", len(physical), len(digital))
+	}
+
+	n, err := sr.ReadDigital(digital)
+	sr.toPhysical(digital[:n], physical)
+
+	return n, err
+}
+
+// toPhysical converts digital sample values to physical values.
+func (sr *SignalReader) toPhysical(digital []int32, physical []float64) {
+	signal := sr.hdr.Signals[sr.signalIndex]
+	for i, value := range digital {
+		if signal.IsStatus() {
+			physical[i] = float64(value)
+		} else {
+			physical[i] = convertDigitalToPhysical(value, signal.DigitalMin, signal.DigitalMax, signal.PhysicalMin, signal.PhysicalMax)
+			if sr.clamp {
+				physical[i] = clampPhysical(physical[i], signal)
+			}
+		}
+	}
+}
+
+// NumSamples returns the number of samples in the signal. If the header declares -1
+// data records, they are counted from the size of the file.
+func (sr *SignalReader) NumSamples() int64 {
+	if sr.dataRecords < 0 {
+		return 0
+	}
+	return int64(sr.dataRecords) * int64(sr.samplesPerRecord)
+}
+
+// ReadDigital reads raw digital sample values from the signal, without applying
+// the signal's calibration.
+func (sr *SignalReader) ReadDigital(data []int32) (int, error) {
+	if err := setReadDeadline(sr.r, sr.timeout); err != nil {
+		return 0, fmt.Errorf("error setting read deadline: %w", err)
+	}
 
 	n := 0
 	for n < len(data) {
-		if sr.currentRecord >= sr.hdr.DataRecords {
+		if sr.currentRecord >= sr.dataRecords {
 			return n, io.EOF // End of data records
 		}
 
-		// Calculate position to read the digital sample from
-		pos := int64(sr.hdr.HeaderBytes) + int64(sr.currentRecord)*int64(sr.recordSize) + int64(sr.signalOffset) + int64(sr.currentSample*2)
-		if _, err := sr.r.Seek(pos, io.SeekStart); err != nil {
-			return n, fmt.Errorf("error seeking to position: %w", err)
+		if err := sr.loadBlock(); err != nil {
+			return n, err
 		}
 
-		// Read the digital sample
-		if _, err := io.ReadFull(sr.r, buf); err != nil {
-			return n, fmt.Errorf("error reading sample data: %w", err)
+		// Decode as much of the current record's block as is wanted.
+		block := sr.block[(sr.currentRecord-sr.blockStart)*sr.samplesPerRecord*sr.sampleWidth:]
+		for sr.currentSample < sr.samplesPerRecord && n < len(data) {
+			data[n] = decodeSample(block[sr.currentSample*sr.sampleWidth:], sr.sampleWidth, sr.byteOrder)
+			n++
+			sr.currentSample++
 		}
-		digitalValue := int16(binary.LittleEndian.Uint16(buf))
-		signal := sr.hdr.Signals[sr.signalIndex]
-		data[n] = convertDigitalToPhysical(digitalValue, signal.DigitalMin, signal.DigitalMax, signal.PhysicalMin, signal.PhysicalMax)
 
-		n++
-
-		// Move to the next sample
-		sr.currentSample++
+		// Move to the next record
 		if sr.currentSample >= sr.samplesPerRecord {
 			sr.currentSample = 0
 			sr.currentRecord++
@@ -256,26 +945,257 @@ func (sr *SignalReader) Read(data []float64) (int, error) {
 	return n, nil
 }
 
+// maxBlockBytes limits how much of a signal is read ahead when its blocks are
+// contiguous in the file.
+const maxBlockBytes = 64 << 10
+
+// loadBlock makes sure the signal's block of samples for the current record is
+// buffered, reading it with a single seek and read. For a file holding only this
+// signal, its blocks are contiguous and several records are read at once.
+func (sr *SignalReader) loadBlock() error {
+	if sr.currentRecord >= sr.blockStart && sr.currentRecord < sr.blockStart+sr.blockRecords {
+		return nil
+	}
+
+	blockBytes := sr.samplesPerRecord * sr.sampleWidth
+	records := 1
+	if int64(blockBytes) == sr.recordSize && blockBytes > 0 {
+		records = maxBlockBytes / blockBytes
+		if records < 1 {
+			records = 1
+		}
+		if remaining := sr.dataRecords - sr.currentRecord; records > remaining {
+			records = remaining
+		}
+	}
+
+	if cap(sr.block) < records*blockBytes {
+		sr.block = make([]byte, records*blockBytes)
+	}
+	sr.block = sr.block[:records*blockBytes]
+	sr.blockRecords = 0
+
+	pos := int64(sr.hdr.HeaderBytes) + int64(sr.currentRecord)*sr.recordSize + sr.signalOffset
+	read, err := sr.file.readFullAt(sr.block, pos)
+	if err != nil {
+		// Keep whatever complete records were read before the file ended.
+		if !errors.Is(err, io.ErrUnexpectedEOF) || blockBytes == 0 || read < blockBytes {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = ErrTruncatedData
+			}
+			return fmt.Errorf("error reading sample data: %w", err)
+		}
+		records = read / blockBytes
+	}
+
+	sr.blockStart = sr.currentRecord
+	sr.blockRecords = records
+
+	return nil
+}
+
+// ReadRecordFirstSamples reads the first sample of the signal from each consecutive data
+// record, giving a cheap overview of the whole signal downsampled to one value per
+// record, e.g. for rendering a thumbnail of a full night. Reading starts at the current
+// record, or at the next one if the reader is partway through a record, and leaves the
+// reader positioned at the start of the record following the last one read. On an
+// error, the samples read before it are returned along with it.
+func (sr *SignalReader) ReadRecordFirstSamples(data []float64) (int, error) {
+	if err := setReadDeadline(sr.r, sr.timeout); err != nil {
+		return 0, fmt.Errorf("error setting read deadline: %w", err)
+	}
+
+	if sr.currentSample > 0 {
+		sr.currentSample = 0
+		sr.currentRecord++
+	}
+
+	buf := sr.sample[:sr.sampleWidth]
+	digital := make([]int32, 0, len(data))
+	for len(digital) < len(data) && sr.currentRecord < sr.dataRecords {
+		pos := int64(sr.hdr.HeaderBytes) + int64(sr.currentRecord)*sr.recordSize + sr.signalOffset
+		if _, err := sr.file.readFullAt(buf, pos); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = ErrTruncatedData
+			}
+			// Return the samples read so far, so the caller can resume from the failed record.
+			sr.toPhysical(digital, data)
+			return len(digital), fmt.Errorf("error reading sample data: %w", err)
+		}
+		digital = append(digital, decodeSample(buf, sr.sampleWidth, sr.byteOrder))
+
+		sr.currentRecord++
+	}
+
+	sr.toPhysical(digital, data)
+
+	if len(digital) < len(data) {
+		return len(digital), io.EOF
+	}
+
+	return len(digital), nil
+}
+
+// Stream reads the signal in a background goroutine, sending physical values on the
+// returned channel until the end of the signal, a read error, or cancellation of ctx,
+// at which point the channel is closed. The channel has a buffer of bufSize samples;
+// once it fills, reading pauses until the consumer catches up. After the channel is
+// closed, StreamErr reports why the stream ended. The SignalReader must not be used
+// for anything else until the channel has been closed.
+func (sr *SignalReader) Stream(ctx context.Context, bufSize int) <-chan float64 {
+	ch := make(chan float64, bufSize)
+	sr.streamErr = nil
+
+	go func() {
+		defer close(ch)
+
+		chunk := bufSize
+		if chunk < 1 {
+			chunk = 1
+		}
+		samples := make([]float64, chunk)
+		for {
+			n, err := sr.Read(samples)
+			for _, sample := range samples[:n] {
+				select {
+				case ch <- sample:
+				case <-ctx.Done():
+					sr.streamErr = ctx.Err()
+					return
+				}
+			}
+
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					sr.streamErr = err
+				}
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// StreamErr returns the error that ended the last Stream, or nil if the stream reached
+// the end of the signal. It must only be called once the stream's channel is closed.
+func (sr *SignalReader) StreamErr() error {
+	return sr.streamErr
+}
+
+// sharedFile is the file underlying a Reader, shared with its SignalReaders.
+type sharedFile struct {
+	r  io.ReadSeeker
+	ra io.ReaderAt // Read from instead of seeking r, if set.
+	mu sync.Mutex  // Guards the position of r.
+}
+
+// readFullAt reads exactly len(b) bytes at offset pos, with the same results as
+// io.ReadFull. Unless the file is read with ReadAt, the seek and read are made holding
+// the lock, so they aren't interleaved with those of SignalReaders used from other
+// goroutines.
+func (f *sharedFile) readFullAt(b []byte, pos int64) (int, error) {
+	if f.ra != nil {
+		n, err := f.ra.ReadAt(b, pos)
+		if n == len(b) {
+			return n, nil
+		}
+		if errors.Is(err, io.EOF) && n > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.r.Seek(pos, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("error seeking to position: %w", err)
+	}
+
+	return io.ReadFull(f.r, b)
+}
+
+// size returns the size of the file.
+func (f *sharedFile) size() (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.r.Seek(0, io.SeekEnd)
+}
+
+// setReadDeadline sets a read deadline timeout from now on r, if r supports deadlines
+// and a timeout has been configured.
+func setReadDeadline(r io.Reader, timeout time.Duration) error {
+	d, ok := r.(interface{ SetReadDeadline(time.Time) error })
+	if !ok || timeout <= 0 {
+		return nil
+	}
+	return d.SetReadDeadline(time.Now().Add(timeout))
+}
+
+// decodeSample decodes a single signed sample of the given size in bytes. 24-bit BDF
+// samples are always little-endian.
+func decodeSample(b []byte, sampleBytes int, byteOrder binary.ByteOrder) int32 {
+	if sampleBytes == 3 {
+		v := int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16
+		return v << 8 >> 8 // Sign extend from 24 bits.
+	}
+	return int32(int16(byteOrder.Uint16(b)))
+}
+
+// headerDateLayouts are the layouts tried, in order, when parsing the start date header
+// field. The standard dd.mm.yy layout comes first, followed by the dd.mm.yyyy layout
+// some exporters write despite the field being only 8 bytes wide.
+var headerDateLayouts = []string{"02.01.06", "02.01.2006"}
+
+// parseHeaderDate parses the start date header field, trying each of headerDateLayouts
+// in turn. The error is that of the standard layout if none match. Two digit years
+// follow the EDF clipping rule: 85-99 are 1985-1999 and 00-84 are 2000-2084.
+func parseHeaderDate(s string) (time.Time, error) {
+	var firstErr error
+	for _, layout := range headerDateLayouts {
+		date, err := time.Parse(layout, s)
+		if err == nil {
+			// Go maps two digit years of 69-99 to the 1900s, so move 69-84 on a century.
+			if layout == "02.01.06" && date.Year() < 1985 {
+				date = date.AddDate(100, 0, 0)
+			}
+			return date, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
+
 // convertDigitalToPhysical converts a digital value from the data record to a physical value using the calibration factors.
-func convertDigitalToPhysical(digital int16, dmin, dmax int, pmin, pmax float64) float64 {
+func convertDigitalToPhysical(digital int32, dmin, dmax int, pmin, pmax float64) float64 {
 	if dmax == dmin {
 		return 0 // Avoid division by zero
 	}
 	return pmin + (float64(digital)-float64(dmin))*(pmax-pmin)/float64(dmax-dmin)
 }
 
-func parseFloat(b []byte) float64 {
-	f, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
-	if err != nil {
-		return 0.0
-	}
-	return f
+// clampPhysical limits a physical value to the signal's declared physical range.
+func clampPhysical(physical float64, signal SignalHeader) float64 {
+	lo, hi := math.Min(signal.PhysicalMin, signal.PhysicalMax), math.Max(signal.PhysicalMin, signal.PhysicalMax)
+	return math.Max(lo, math.Min(hi, physical))
 }
 
-func parseInt(b []byte) int {
-	i, err := strconv.Atoi(strings.TrimSpace(string(b)))
-	if err != nil {
-		return 0
-	}
-	return i
+// fitsSample reports whether v can be stored in a signed sample of the given size.
+func fitsSample(v int, sampleBytes int) bool {
+	limit := 1 << (sampleBytes*8 - 1)
+	return v >= -limit && v < limit
+}
+
+// parseFloat parses a numeric header field, ignoring the spaces padding it out.
+func parseFloat(b []byte) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+}
+
+// parseInt parses an integer header field, ignoring the spaces padding it out.
+func parseInt(b []byte) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(string(b)))
 }
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The start date field is only 8 bytes wide, so four digit years can't be written
+// through the public API and the parser is tested directly.
+func TestParseHeaderDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		date    string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "TwoDigitYear",
+			date: "25.12.24",
+			want: time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "ClippingRuleLastTwentiethCenturyYear",
+			date: "31.12.99",
+			want: time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "ClippingRuleFirstTwentiethCenturyYear",
+			date: "01.01.85",
+			want: time.Date(1985, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "ClippingRuleLastTwentyFirstCenturyYear",
+			date: "31.12.84",
+			want: time.Date(2084, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "ClippingRuleFirstTwentyFirstCenturyYear",
+			date: "01.01.00",
+			want: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "ClippingRuleBeyondGoPivot",
+			date: "15.06.69",
+			want: time.Date(2069, 6, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "FourDigitYear",
+			date: "25.12.2024",
+			want: time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "FourDigitYearLastCentury",
+			date: "01.02.1960",
+			want: time.Date(1960, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Invalid",
+			date:    "2024-12-25",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, err := parseHeaderDate(tt.date)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, date)
+		})
+	}
+}
+
+// Go releases before 1.20 don't unwrap errors holding several, so multiError's own Is
+// and As are tested directly.
+func TestMultiError(t *testing.T) {
+	err := multiError{errors.New("first"), &FieldError{Field: "Version", Err: ErrInvalidDate}}
+
+	require.True(t, err.Is(ErrInvalidDate))
+	require.False(t, err.Is(ErrShortHeader))
+
+	var fieldErr *FieldError
+	require.True(t, err.As(&fieldErr))
+	require.Equal(t, "Version", fieldErr.Field)
+}
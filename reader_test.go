@@ -10,8 +10,17 @@
 package edf_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/OpenPSG/edf"
 	"github.com/stretchr/testify/assert"
@@ -53,3 +62,1770 @@ func TestReader(t *testing.T) {
 	assert.InDelta(t, -0.212, samples[7498], 0.001)
 	assert.InDelta(t, -0.206, samples[7499], 0.001)
 }
+
+func TestReaderSignalMetadata(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	meta := er.SignalMetadata()
+	require.Len(t, meta, 4)
+
+	assert.Equal(t, "Flow.40ms", meta[0].Label)
+	assert.Equal(t, "L/s", meta[0].Unit)
+	assert.InDelta(t, 25.0, meta[0].SampleRate, 1e-9)
+	assert.InDelta(t, 0.002, meta[0].Resolution, 1e-9)
+	assert.False(t, meta[0].Annotations)
+
+	assert.Equal(t, "Press.40ms", meta[1].Label)
+	assert.Equal(t, "cmH2O", meta[1].Unit)
+	assert.InDelta(t, 0.02, meta[1].Resolution, 1e-9)
+
+	assert.Equal(t, "Crc16", meta[3].Label)
+	assert.InDelta(t, 1.0/60, meta[3].SampleRate, 1e-9)
+}
+
+func TestReaderSignalMetadataPrefiltering(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				TransducerType:    "AgAgCl electrode",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				Prefiltering:      "HP:0.1Hz LP:75Hz N:50Hz",
+				SamplesPerRecord:  256,
+			},
+			{
+				Label:            edf.AnnotationsLabel,
+				PhysicalMin:      -1,
+				PhysicalMax:      1,
+				DigitalMin:       -32768,
+				DigitalMax:       32767,
+				SamplesPerRecord: 30,
+			},
+		},
+	}
+
+	er, err := edf.Open(writeTestFile(t, hdr, nil))
+	require.NoError(t, err)
+
+	meta := er.SignalMetadata()
+	require.Len(t, meta, 2)
+
+	assert.Equal(t, "AgAgCl electrode", meta[0].Transducer)
+	assert.InDelta(t, 0.1, meta[0].HighPass, 1e-9)
+	assert.InDelta(t, 75.0, meta[0].LowPass, 1e-9)
+	assert.InDelta(t, 50.0, meta[0].Notch, 1e-9)
+
+	assert.True(t, meta[1].Annotations)
+	assert.Zero(t, meta[1].SampleRate)
+}
+
+func TestReaderDigitalRangeOutOfBounds(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  256,
+			},
+		},
+	}
+
+	// The writer refuses such a header, so patch in the out of range DigitalMax.
+	f := writeTestFile(t, hdr, nil)
+	patchTestFile(t, f, 384, "40000   ")
+
+	_, err := edf.Open(f)
+	require.ErrorContains(t, err, "exceeds 16-bit sample range")
+}
+
+func TestReaderMaxRecords(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f, edf.WithMaxRecords(10))
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	// 10 records of 1500 samples, then EOF.
+	samples := make([]float64, 20000)
+	n, err := sr.Read(samples)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 15000, n)
+}
+
+func TestReaderRecordDurationSeconds(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "ECG",
+				PhysicalDimension: "mV",
+				PhysicalMin:       -5,
+				PhysicalMax:       5,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  1,
+			},
+		},
+	}
+
+	f := writeTestFile(t, hdr, nil)
+
+	// One sample per 4 ms record, e.g. a 250 Hz signal.
+	patchTestFile(t, f, 244, "0.004   ")
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	require.Equal(t, 0.004, er.RecordDurationSeconds())
+}
+
+// slowReader is an io.ReadSeeker that takes delay to service each read, and honors
+// read deadlines.
+type slowReader struct {
+	*bytes.Reader
+	delay    time.Duration
+	deadline time.Time
+}
+
+func (r *slowReader) SetReadDeadline(t time.Time) error {
+	r.deadline = t
+	return nil
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if !r.deadline.IsZero() && time.Now().Add(r.delay).After(r.deadline) {
+		time.Sleep(time.Until(r.deadline))
+		return 0, os.ErrDeadlineExceeded
+	}
+	time.Sleep(r.delay)
+	return r.Reader.Read(p)
+}
+
+func TestReaderReadTimeout(t *testing.T) {
+	b, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	t.Run("Fast", func(t *testing.T) {
+		r := &slowReader{Reader: bytes.NewReader(b), delay: time.Millisecond}
+
+		_, err := edf.Open(r, edf.WithReadTimeout(time.Second))
+		require.NoError(t, err)
+	})
+
+	t.Run("Slow", func(t *testing.T) {
+		r := &slowReader{Reader: bytes.NewReader(b), delay: time.Second}
+
+		_, err := edf.Open(r, edf.WithReadTimeout(10*time.Millisecond))
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+}
+
+func TestReaderByteOrderDetection(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  256,
+			},
+		},
+	}
+
+	record := make([]float64, 256)
+	for i := range record {
+		record[i] = float64(i) - 128
+	}
+
+	f := writeTestFile(t, hdr, [][][]float64{{record}})
+
+	// Byte swap every sample to simulate a big-endian writer.
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	for i := 512; i+1 < len(b); i += 2 {
+		b[i], b[i+1] = b[i+1], b[i]
+	}
+
+	er, err := edf.Open(bytes.NewReader(b), edf.WithByteOrderDetection())
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	samples := make([]float64, 256)
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+
+	for i := range samples {
+		require.InDelta(t, record[i], samples[i], 1.0)
+	}
+
+	// A correctly little-endian file is unaffected.
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err = edf.Open(f, edf.WithByteOrderDetection())
+	require.NoError(t, err)
+
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.InDelta(t, record[200], samples[200], 1.0)
+}
+
+func TestReaderDataOffset(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	// 256 bytes of fixed header plus 256 bytes for each of the 4 signals.
+	require.Equal(t, int64(1280), er.DataOffset())
+	require.Empty(t, er.Lint())
+}
+
+func TestReaderStatusSignal(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            edf.StatusLabel,
+				TransducerType:   "Triggers and Status",
+				PhysicalMin:      -1,
+				PhysicalMax:      1,
+				DigitalMin:       -32768,
+				DigitalMax:       32767,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	codes := []int16{0, 1, 5, -32768}
+	record := make([]byte, 8)
+	for i, code := range codes {
+		binary.LittleEndian.PutUint16(record[i*2:], uint16(code))
+	}
+
+	er, err := edf.Open(writeRawTestFile(t, hdr, [][]byte{record}))
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	// Status values are returned raw, not calibrated.
+	samples := make([]float64, 4)
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, []float64{0, 1, 5, -32768}, samples)
+
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+
+	digital := make([]int32, 4)
+	_, err = sr.ReadDigital(digital)
+	require.NoError(t, err)
+
+	require.Equal(t, uint16(0), edf.TriggerBits(digital[0]))
+	require.Equal(t, uint16(1), edf.TriggerBits(digital[1]))
+	require.Equal(t, uint16(0b101), edf.TriggerBits(digital[2]))
+	require.Equal(t, uint16(0x8000), edf.TriggerBits(digital[3]))
+}
+
+func TestReaderReadBoth(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	physical := make([]float64, 3000)
+	digital := make([]int32, 3000)
+	n, err := sr.ReadBoth(physical, digital)
+	require.NoError(t, err)
+	require.Equal(t, 3000, n)
+
+	// Compare against separate reads of each kind.
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+
+	expectedPhysical := make([]float64, 3000)
+	_, err = sr.Read(expectedPhysical)
+	require.NoError(t, err)
+
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+
+	expectedDigital := make([]int32, 3000)
+	_, err = sr.ReadDigital(expectedDigital)
+	require.NoError(t, err)
+
+	require.Equal(t, expectedPhysical, physical)
+	require.Equal(t, expectedDigital, digital)
+
+	_, err = sr.ReadBoth(make([]float64, 2), make([]int32, 3))
+	require.Error(t, err)
+}
+
+func TestReaderClampedPhysicalValues(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  4,
+			},
+		},
+	}
+
+	// Digital codes beyond the declared range on both sides.
+	record := make([]byte, 8)
+	for i, code := range []int16{-2500, -1024, 1024, 2500} {
+		binary.LittleEndian.PutUint16(record[i*2:], uint16(code))
+	}
+
+	f := writeRawTestFile(t, hdr, [][]byte{record})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	samples := make([]float64, 4)
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.Less(t, samples[0], -500.0)
+	require.Greater(t, samples[3], 500.0)
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err = edf.Open(f, edf.WithClampedPhysicalValues())
+	require.NoError(t, err)
+
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, -500.0, samples[0])
+	require.InDelta(t, -250.0, samples[1], 1.0)
+	require.InDelta(t, 250.0, samples[2], 1.0)
+	require.Equal(t, 500.0, samples[3])
+}
+
+func TestReaderTruncatedHeader(t *testing.T) {
+	b, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	// The physical dimensions of the four signals start at byte 640, so this cuts the
+	// header off partway through the second signal's dimension.
+	_, err = edf.Open(bytes.NewReader(b[:650]))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Contains(t, err.Error(), "Signal[1].PhysicalDimension at byte 648")
+	assert.Contains(t, err.Error(), "have 2 of 8 bytes")
+
+	// Truncated within the fixed header.
+	_, err = edf.Open(bytes.NewReader(b[:100]))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Contains(t, err.Error(), "RecordingID at byte 88")
+}
+
+func TestSignalReaderReadRecordFirstSamples(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	hdr := er.Header()
+	samplesPerRecord := hdr.Signals[0].SamplesPerRecord
+
+	// Read the signal at full resolution and pick out the first sample of each record.
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	full := make([]float64, hdr.DataRecords*samplesPerRecord)
+	_, err = sr.Read(full)
+	require.NoError(t, err)
+
+	expected := make([]float64, hdr.DataRecords)
+	for i := range expected {
+		expected[i] = full[i*samplesPerRecord]
+	}
+
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+
+	overview := make([]float64, hdr.DataRecords+1)
+	n, err := sr.ReadRecordFirstSamples(overview)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, hdr.DataRecords, n)
+	require.Equal(t, expected, overview[:n])
+
+	// Partway through a record, reading resumes at the next record.
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+
+	_, err = sr.Read(make([]float64, 10))
+	require.NoError(t, err)
+
+	overview = make([]float64, 2)
+	n, err = sr.ReadRecordFirstSamples(overview)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, expected[1:3], overview)
+
+	t.Run("Truncated", func(t *testing.T) {
+		b, err := os.ReadFile("testdata/resmed_BRP.edf")
+		require.NoError(t, err)
+
+		// Drop the last data record.
+		recordSize := len(b[hdr.HeaderBytes:]) / hdr.DataRecords
+		er, err := edf.Open(bytes.NewReader(b[:len(b)-recordSize]))
+		require.NoError(t, err)
+
+		sr, err := er.Signal(0)
+		require.NoError(t, err)
+
+		overview := make([]float64, hdr.DataRecords)
+		n, err := sr.ReadRecordFirstSamples(overview)
+		require.ErrorIs(t, err, edf.ErrTruncatedData)
+		require.Equal(t, hdr.DataRecords-1, n)
+		require.Equal(t, expected[:n], overview[:n])
+	})
+}
+
+func TestReaderRecordingSpanningMidnight(t *testing.T) {
+	start := time.Date(2024, 12, 12, 23, 30, 0, 0, time.UTC)
+
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          start,
+		DataRecordDuration: time.Minute,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "SpO2",
+				PhysicalMin:      0,
+				PhysicalMax:      100,
+				DigitalMin:       0,
+				DigitalMax:       1000,
+				SamplesPerRecord: 60,
+			},
+		},
+	}
+
+	// Two hours of one minute records.
+	records := make([][][]float64, 120)
+	for i := range records {
+		records[i] = [][]float64{make([]float64, 60)}
+	}
+
+	er, err := edf.Open(writeTestFile(t, hdr, records))
+	require.NoError(t, err)
+
+	hdr = er.Header()
+	require.Equal(t, start, hdr.StartTime)
+
+	// Absolute sample times are derived from the start time with time.Time arithmetic,
+	// so they roll over into the next day rather than wrapping at midnight.
+	epochs, err := er.EpochStats(0, 30*time.Minute)
+	require.NoError(t, err)
+	require.Len(t, epochs, 4)
+
+	expected := []time.Time{
+		time.Date(2024, 12, 12, 23, 30, 0, 0, time.UTC),
+		time.Date(2024, 12, 13, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 12, 13, 0, 30, 0, 0, time.UTC),
+		time.Date(2024, 12, 13, 1, 0, 0, 0, time.UTC),
+	}
+	for i, epoch := range epochs {
+		require.Equal(t, expected[i], hdr.StartTime.Add(epoch.Start))
+	}
+
+	last := hdr.StartTime.Add(time.Duration(hdr.DataRecords) * hdr.DataRecordDuration)
+	require.Equal(t, time.Date(2024, 12, 13, 1, 30, 0, 0, time.UTC), last)
+}
+
+func TestReaderHighestRateSignal(t *testing.T) {
+	signal := func(label string, samplesPerRecord int) edf.SignalHeader {
+		return edf.SignalHeader{
+			Label:            label,
+			PhysicalMin:      -1,
+			PhysicalMax:      1,
+			DigitalMin:       -32768,
+			DigitalMax:       32767,
+			SamplesPerRecord: samplesPerRecord,
+		}
+	}
+
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: 2 * time.Second,
+		SignalCount:        4,
+		Signals: []edf.SignalHeader{
+			signal("Resp", 20),
+			signal("EEG", 512),
+			signal("ECG", 512),
+			signal(edf.AnnotationsLabel, 1024),
+		},
+	}
+
+	er, err := edf.Open(writeRawTestFile(t, hdr, nil))
+	require.NoError(t, err)
+
+	index, rate := er.HighestRateSignal()
+	require.Equal(t, 1, index)
+	require.Equal(t, 256.0, rate)
+
+	// A file with only an annotations signal has no data signals.
+	hdr.SignalCount = 1
+	hdr.Signals = hdr.Signals[3:]
+
+	er, err = edf.Open(writeRawTestFile(t, hdr, nil))
+	require.NoError(t, err)
+
+	index, _ = er.HighestRateSignal()
+	require.Equal(t, -1, index)
+}
+
+func TestHeaderString(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	summary := er.Header().String()
+
+	assert.Contains(t, summary, `patient "X X X X"`)
+	assert.Contains(t, summary, "started 2024-12-12")
+	assert.Contains(t, summary, "4 signals")
+	assert.Contains(t, summary, "40 records (40m0s)")
+	assert.NotContains(t, summary, "\n")
+	assert.True(t, strings.HasPrefix(summary, "EDF version"))
+
+	hdr := er.Header()
+	hdr.DataRecords = -1
+	assert.Contains(t, hdr.String(), "unknown number of records")
+
+	// The dialect follows the parsed type, not the text of the reserved field.
+	hdr.Type = edf.EDFPlusDiscontinuous
+	hdr.Reserved = ""
+	assert.True(t, strings.HasPrefix(hdr.String(), "EDF+D version"))
+
+	hdr.Version = edf.VersionBDF
+	assert.True(t, strings.HasPrefix(hdr.String(), "BDF+D version"))
+
+	hdr.Type = edf.Plain
+	hdr.Reserved = "EDF+C"
+	assert.True(t, strings.HasPrefix(hdr.String(), "BDF version"))
+}
+
+func TestHeaderTotalDuration(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	hdr := er.Header()
+
+	total, err := hdr.TotalDuration()
+	require.NoError(t, err)
+	require.Equal(t, 40*time.Minute, total)
+
+	end, err := hdr.EndTime()
+	require.NoError(t, err)
+	require.Equal(t, hdr.StartTime.Add(40*time.Minute), end)
+
+	hdr.DataRecords = -1
+
+	total, err = hdr.TotalDuration()
+	require.ErrorIs(t, err, edf.ErrUnknownRecordCount)
+	require.Zero(t, total)
+
+	end, err = hdr.EndTime()
+	require.ErrorIs(t, err, edf.ErrUnknownRecordCount)
+	require.Equal(t, hdr.StartTime, end)
+}
+
+func TestHeaderSampleRate(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	hdr := er.Header()
+	require.Equal(t, 25.0, hdr.SampleRate(0))
+	require.Equal(t, 1.0/60, hdr.SampleRate(3))
+	require.Zero(t, hdr.SampleRate(4))
+
+	signal := edf.SignalHeader{SamplesPerRecord: 7}
+	require.Equal(t, 70.0, signal.SampleRate(100*time.Millisecond))
+	require.Equal(t, 2.8, signal.SampleRate(2500*time.Millisecond))
+	require.Zero(t, signal.SampleRate(0))
+}
+
+func TestParseHeader(t *testing.T) {
+	b, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	er, err := edf.Open(bytes.NewReader(b))
+	require.NoError(t, err)
+	want := er.Header()
+
+	// Just the header, or the whole file.
+	for _, n := range []int{256 * 5, len(b)} {
+		hdr, err := edf.ParseHeader(b[:n])
+		require.NoError(t, err)
+		require.Equal(t, want, *hdr)
+	}
+
+	_, err = edf.ParseHeader(b[:256*5-1])
+	require.ErrorIs(t, err, edf.ErrShortHeader)
+
+	var fieldErr *edf.FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	require.Equal(t, "Signal[3].Reserved", fieldErr.Field)
+
+	// The slice must also hold the size the header declares.
+	header := append([]byte(nil), b[:256*5]...)
+	copy(header[184:], "1536    ")
+	_, err = edf.ParseHeader(header)
+	require.ErrorIs(t, err, edf.ErrShortHeader)
+}
+
+func TestSignalReaderStream(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(1)
+	require.NoError(t, err)
+
+	expected := make([]float64, 40*1500)
+	_, err = sr.Read(expected)
+	require.NoError(t, err)
+
+	sr, err = er.Signal(1)
+	require.NoError(t, err)
+
+	var streamed []float64
+	for sample := range sr.Stream(context.Background(), 1024) {
+		streamed = append(streamed, sample)
+	}
+	require.NoError(t, sr.StreamErr())
+	require.Equal(t, expected, streamed)
+
+	// Cancelling the context ends the stream early.
+	sr, err = er.Signal(1)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var count int
+	for range sr.Stream(ctx, 16) {
+		count++
+		if count == 100 {
+			cancel()
+		}
+	}
+	require.ErrorIs(t, sr.StreamErr(), context.Canceled)
+	require.Less(t, count, len(expected))
+}
+
+func TestSignalReaderReadAllocations(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	samples := make([]float64, 256)
+
+	// Warm up the reader's scratch buffers.
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := sr.Read(samples); err != nil {
+			t.Fatal(err)
+		}
+	})
+	require.Zero(t, allocs)
+}
+
+func BenchmarkSignalReaderRead(b *testing.B) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(b, err)
+	b.Cleanup(func() {
+		require.NoError(b, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(b, err)
+
+	samples := make([]float64, 1500)
+
+	sr, err := er.Signal(0)
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := sr.Read(samples); err != nil {
+			// Start over once the end of the signal is reached.
+			b.StopTimer()
+			sr, err = er.Signal(0)
+			require.NoError(b, err)
+			b.StartTimer()
+		}
+	}
+}
+
+func TestReaderAlignment(t *testing.T) {
+	signal := func(label string, samplesPerRecord int) edf.SignalHeader {
+		return edf.SignalHeader{
+			Label:            label,
+			PhysicalMin:      -1,
+			PhysicalMax:      1,
+			DigitalMin:       -32768,
+			DigitalMax:       32767,
+			SamplesPerRecord: samplesPerRecord,
+		}
+	}
+
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        4,
+		Signals: []edf.SignalHeader{
+			signal("EEG C3", 256),
+			signal("EEG C4", 256),
+			signal("Resp", 32),
+			signal("SpO2", 3),
+		},
+	}
+
+	er, err := edf.Open(writeRawTestFile(t, hdr, nil))
+	require.NoError(t, err)
+
+	require.True(t, er.AreAligned(0, 1))
+	require.False(t, er.AreAligned(0, 2))
+	require.False(t, er.AreAligned(0, 4))
+
+	na, nb := er.AlignmentFactor(0, 1)
+	require.Equal(t, []int{1, 1}, []int{na, nb})
+
+	na, nb = er.AlignmentFactor(0, 2)
+	require.Equal(t, []int{8, 1}, []int{na, nb})
+
+	na, nb = er.AlignmentFactor(3, 2)
+	require.Equal(t, []int{3, 32}, []int{na, nb})
+
+	na, nb = er.AlignmentFactor(0, -1)
+	require.Equal(t, []int{0, 0}, []int{na, nb})
+}
+
+func TestReaderStrictOverclaimingHeader(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -1,
+				PhysicalMax:      1,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	f := writeTestFile(t, hdr, [][][]float64{{make([]float64, 4)}, {make([]float64, 4)}})
+
+	_, err := edf.Open(f, edf.WithStrict())
+	require.NoError(t, err)
+
+	// Claim far more data records than were written.
+	patchTestFile(t, f, 236, "1000    ")
+
+	_, err = edf.Open(f)
+	require.NoError(t, err)
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	_, err = edf.Open(f, edf.WithStrict())
+	require.ErrorContains(t, err, "header claims 1000 data records of 8 bytes (8512 bytes in total), but the file is only 528 bytes")
+}
+
+func TestReaderReadAllByLabel(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"Flow.40ms", "Press.40ms", "TrigCycEvt.40ms", "Crc16"}, er.Labels())
+
+	signals, err := er.ReadAllByLabel()
+	require.NoError(t, err)
+	require.Len(t, signals, 4)
+
+	for i, label := range er.Labels() {
+		expected, err := er.ReadAll(i)
+		require.NoError(t, err)
+		require.Len(t, expected, 40*er.Header().Signals[i].SamplesPerRecord)
+		require.Equal(t, expected, signals[label])
+	}
+}
+
+func TestReaderReadAllByLabelDuplicates(t *testing.T) {
+	signal := edf.SignalHeader{
+		Label:            "EEG",
+		PhysicalMin:      -1,
+		PhysicalMax:      1,
+		DigitalMin:       -2048,
+		DigitalMax:       2047,
+		SamplesPerRecord: 4,
+	}
+
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals:            []edf.SignalHeader{signal, signal},
+	}
+
+	er, err := edf.Open(writeTestFile(t, hdr, nil))
+	require.NoError(t, err)
+
+	_, err = er.ReadAllByLabel()
+	require.ErrorContains(t, err, `duplicate signal label "EEG"`)
+}
+
+func TestReaderEndTime(t *testing.T) {
+	b, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	er, err := edf.Open(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	end, err := er.EndTime()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 12, 12, 3, 30, 56, 0, time.UTC), end)
+
+	// The records of a file that was never finalized are counted from its size.
+	copy(b[236:], "-1      ")
+
+	er, err = edf.Open(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	end, err = er.EndTime()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 12, 12, 3, 30, 56, 0, time.UTC), end)
+}
+
+func TestReaderUnknownRecordCount(t *testing.T) {
+	b, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	er, err := edf.Open(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	flow, err := er.ReadAll(0)
+	require.NoError(t, err)
+
+	// A recording interrupted partway through its last record, before the header was
+	// finalized.
+	unknown := append([]byte(nil), b[:len(b)-100]...)
+	copy(unknown[236:], "-1      ")
+
+	er, err = edf.Open(bytes.NewReader(unknown))
+	require.NoError(t, err)
+	require.Equal(t, 39, er.Header().DataRecords)
+
+	samples, err := er.ReadAll(0)
+	require.NoError(t, err)
+	require.Equal(t, flow[:39*1500], samples)
+
+	// The missing count is still reported by Validate.
+	require.ErrorIs(t, edf.Validate(bytes.NewReader(unknown)), edf.ErrUnknownRecordCount)
+}
+
+func TestSignalReaderTransforms(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -500,
+				PhysicalMax:      500,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	f := writeTestFile(t, hdr, [][][]float64{{{-300, -100, 100, 300}}})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	scale := func(samples []float64) {
+		for i := range samples {
+			samples[i] *= 2
+		}
+	}
+	clamp := func(samples []float64) {
+		for i := range samples {
+			samples[i] = math.Max(-250, math.Min(250, samples[i]))
+		}
+	}
+
+	// Scale, then clamp the scaled values.
+	sr.AddTransform(scale)
+	sr.AddTransform(clamp)
+
+	samples := make([]float64, 4)
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+
+	for i, want := range []float64{-250, -200, 200, 250} {
+		require.InDelta(t, want, samples[i], 1.0)
+	}
+
+	// The digital values are untouched.
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+	sr.AddTransform(scale)
+
+	digital := make([]int32, 4)
+	_, err = sr.ReadDigital(digital)
+	require.NoError(t, err)
+	require.InDelta(t, -1229, digital[0], 1)
+}
+
+func TestReaderBDF(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.VersionBDF,
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fp1",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -8388608,
+				PhysicalMax:       8388607,
+				DigitalMin:        -8388608,
+				DigitalMax:        8388607,
+				SamplesPerRecord:  4,
+			},
+			{
+				Label:            edf.StatusLabel,
+				PhysicalMin:      -8388608,
+				PhysicalMax:      8388607,
+				DigitalMin:       -8388608,
+				DigitalMax:       8388607,
+				SamplesPerRecord: 2,
+			},
+		},
+	}
+
+	// put24 appends a 24-bit little-endian sample.
+	put24 := func(b []byte, v int32) []byte {
+		return append(b, byte(v), byte(v>>8), byte(v>>16))
+	}
+
+	var record []byte
+	for _, v := range []int32{-8388608, -1, 1, 8388607, 0x123456, 0x0000ff} {
+		record = put24(record, v)
+	}
+
+	er, err := edf.Open(writeRawTestFile(t, hdr, [][]byte{record, record}))
+	require.NoError(t, err)
+	require.Equal(t, 3, er.Header().BytesPerSample)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	digital := make([]int32, 8)
+	n, err := sr.ReadDigital(digital)
+	require.NoError(t, err)
+	require.Equal(t, 8, n)
+	require.Equal(t, []int32{-8388608, -1, 1, 8388607, -8388608, -1, 1, 8388607}, digital)
+
+	sr, err = er.Signal(0)
+	require.NoError(t, err)
+
+	samples := make([]float64, 4)
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, []float64{-8388608, -1, 1, 8388607}, samples)
+
+	sr, err = er.Signal(1)
+	require.NoError(t, err)
+
+	status := make([]float64, 2)
+	_, err = sr.Read(status)
+	require.NoError(t, err)
+	require.Equal(t, []float64{0x123456, 0xff}, status)
+}
+
+func TestSignalHeaderTransducerCategory(t *testing.T) {
+	tests := []struct {
+		transducer string
+		want       string
+	}{
+		{"AgAgCl electrode", "electrode"},
+		{"Ag-AgCl cup electrode", "electrode"},
+		{"Nasal thermistor", "thermistor"},
+		{"Thermocouple", "thermocouple"},
+		{"Strain gauge", "strain gauge"},
+		{"Piezo belt", "piezoelectric"},
+		{"Respiratory inductance belt", "inductance plethysmography"},
+		{"Pulse oximeter", "oximeter"},
+		{"Nasal pressure transducer", "pressure transducer"},
+		{"Snore microphone", "microphone"},
+		{"Triaxial accelerometer", "accelerometer"},
+		{"", ""},
+		{"Unknown sensor", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.transducer, func(t *testing.T) {
+			signal := edf.SignalHeader{TransducerType: tt.transducer}
+			assert.Equal(t, tt.want, signal.TransducerCategory())
+		})
+	}
+}
+
+func TestReaderRecordSize(t *testing.T) {
+	t.Run("EDF", func(t *testing.T) {
+		f, err := os.Open("testdata/resmed_BRP.edf")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, f.Close())
+		})
+
+		er, err := edf.Open(f)
+		require.NoError(t, err)
+
+		// 3 signals of 1500 samples and a CRC of 1 sample, 2 bytes each.
+		require.Equal(t, int64(9002), er.RecordSize())
+
+		fi, err := f.Stat()
+		require.NoError(t, err)
+		require.Equal(t, fi.Size(), er.DataOffset()+int64(er.Header().DataRecords)*er.RecordSize())
+	})
+
+	t.Run("BDF", func(t *testing.T) {
+		hdr := edf.Header{
+			Version:            edf.VersionBDF,
+			StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+			DataRecordDuration: time.Second,
+			SignalCount:        2,
+			Signals: []edf.SignalHeader{
+				{
+					Label:            "EEG Fp1",
+					PhysicalMin:      -100,
+					PhysicalMax:      100,
+					DigitalMin:       -8388608,
+					DigitalMax:       8388607,
+					SamplesPerRecord: 4,
+				},
+				{
+					Label:            "EEG Fp2",
+					PhysicalMin:      -100,
+					PhysicalMax:      100,
+					DigitalMin:       -8388608,
+					DigitalMax:       8388607,
+					SamplesPerRecord: 2,
+				},
+			},
+		}
+
+		samples := [][]float64{{1, 2, 3, 4}, {5, 6}}
+		er, err := edf.Open(writeTestFile(t, hdr, [][][]float64{samples, samples, samples}))
+		require.NoError(t, err)
+
+		// 6 samples of 3 bytes each.
+		require.Equal(t, int64(18), er.RecordSize())
+
+		// The second signal starts 12 bytes into each record.
+		got, err := er.ReadAll(1)
+		require.NoError(t, err)
+		require.Len(t, got, 6)
+		for i, want := range []float64{5, 6, 5, 6, 5, 6} {
+			require.InDelta(t, want, got[i], 1e-4)
+		}
+	})
+}
+
+func TestReaderTotalSamples(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	// 40 records of 3 signals of 1500 samples and a CRC of 1 sample.
+	require.Equal(t, int64(40*4501), er.TotalSamples())
+
+	var total int64
+	for i := range er.Header().Signals {
+		sr, err := er.Signal(i)
+		require.NoError(t, err)
+		total += sr.NumSamples()
+	}
+	require.Equal(t, er.TotalSamples(), total)
+
+	// Annotation signals aren't counted.
+	er, err = edf.Open(writeRawTestFile(t, annotatedHeader, [][]byte{
+		annotatedRecord("+0\x14\x14\x00"),
+		annotatedRecord("+1\x14\x14\x00"),
+	}))
+	require.NoError(t, err)
+	require.Equal(t, int64(8), er.TotalSamples())
+}
+
+func TestSignalReaderReadAt(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	all, err := er.ReadAll(0)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	// Advance the sequential position so we can check it's left alone.
+	head := make([]float64, 10)
+	_, err = sr.Read(head)
+	require.NoError(t, err)
+
+	// Straddle the boundary between the first and second records.
+	samples := make([]float64, 20)
+	n, err := sr.ReadAt(samples, 1490)
+	require.NoError(t, err)
+	require.Equal(t, 20, n)
+	require.Equal(t, all[1490:1510], samples)
+
+	// A read running off the end is cut short.
+	n, err = sr.ReadAt(samples, int64(len(all)-5))
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 5, n)
+	require.Equal(t, all[len(all)-5:], samples[:n])
+
+	n, err = sr.ReadAt(samples, int64(len(all)))
+	require.Equal(t, io.EOF, err)
+	require.Zero(t, n)
+
+	_, err = sr.ReadAt(samples, -1)
+	require.Error(t, err)
+
+	// Sequential reads carry on where they left off.
+	_, err = sr.Read(head)
+	require.NoError(t, err)
+	require.Equal(t, all[10:20], head)
+}
+
+func TestSignalReaderSeek(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	all, err := er.ReadAll(0)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	samples := make([]float64, 10)
+
+	pos, err := sr.Seek(1495, io.SeekStart)
+	require.NoError(t, err)
+	require.Equal(t, int64(1495), pos)
+
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, all[1495:1505], samples)
+
+	pos, err = sr.Seek(-5, io.SeekCurrent)
+	require.NoError(t, err)
+	require.Equal(t, int64(1500), pos)
+
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, all[1500:1510], samples)
+
+	pos, err = sr.Seek(-10, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(all)-10), pos)
+
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, all[len(all)-10:], samples)
+
+	// Past the end, reads return io.EOF.
+	_, err = sr.Seek(100, io.SeekEnd)
+	require.NoError(t, err)
+
+	n, err := sr.Read(samples)
+	require.Equal(t, io.EOF, err)
+	require.Zero(t, n)
+
+	_, err = sr.Seek(-1, io.SeekStart)
+	require.Error(t, err)
+
+	_, err = sr.Seek(0, 42)
+	require.Error(t, err)
+}
+
+func TestReaderForEachRecord(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	all, err := er.ReadAll(1)
+	require.NoError(t, err)
+
+	var want float64
+	for _, sample := range all {
+		want += sample
+	}
+
+	var records int
+	var sum float64
+	err = er.ForEachRecord(func(index int, signals [][]float64) error {
+		require.Equal(t, records, index)
+		require.Len(t, signals, 4)
+		require.Len(t, signals[1], 1500)
+
+		for _, sample := range signals[1] {
+			sum += sample
+		}
+		records++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 40, records)
+	require.InDelta(t, want, sum, 1e-6)
+
+	// Errors from the callback stop iteration.
+	stop := errors.New("stop")
+	records = 0
+	err = er.ForEachRecord(func(index int, signals [][]float64) error {
+		records++
+		if index == 2 {
+			return stop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 3, records)
+}
+
+func TestReaderReadFrame(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	all := make([][]float64, 4)
+	for i := range all {
+		all[i], err = er.ReadAll(i)
+		require.NoError(t, err)
+	}
+
+	frames := make([][]float64, 4)
+	for record := 0; ; record++ {
+		frame, err := er.ReadFrame()
+		if err == io.EOF {
+			require.Equal(t, 40, record)
+			break
+		}
+		require.NoError(t, err)
+		require.Len(t, frame, 4)
+
+		for i, signal := range er.Header().Signals {
+			require.Len(t, frame[i], signal.SamplesPerRecord)
+			frames[i] = append(frames[i], frame[i]...)
+		}
+	}
+
+	require.Equal(t, all, frames)
+
+	_, err = er.ReadFrame()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestReaderSignalByLabel(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	press, err := er.ReadAll(1)
+	require.NoError(t, err)
+
+	sr, err := er.SignalByLabel("Press.40ms")
+	require.NoError(t, err)
+
+	samples := make([]float64, len(press))
+	_, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, press, samples)
+
+	_, err = er.SignalByLabel("press.40ms")
+	require.ErrorContains(t, err, `no signal labelled "press.40ms"`)
+
+	hdr := er.Header()
+	index, ok := hdr.SignalIndex(" Press.40ms ")
+	require.True(t, ok)
+	require.Equal(t, 1, index)
+
+	_, ok = hdr.SignalIndex("SpO2")
+	require.False(t, ok)
+
+	t.Run("CaseInsensitive", func(t *testing.T) {
+		_, err := f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		er, err := edf.Open(f, edf.WithCaseInsensitiveLabels())
+		require.NoError(t, err)
+
+		_, err = er.SignalByLabel("press.40ms")
+		require.NoError(t, err)
+	})
+
+	t.Run("Ambiguous", func(t *testing.T) {
+		signal := edf.SignalHeader{
+			Label:            "EEG",
+			PhysicalMin:      -1,
+			PhysicalMax:      1,
+			DigitalMin:       -2048,
+			DigitalMax:       2047,
+			SamplesPerRecord: 1,
+		}
+		er, err := edf.Open(writeTestFile(t, edf.Header{
+			Version:            edf.Version0,
+			StartTime:          time.Now(),
+			DataRecordDuration: time.Second,
+			SignalCount:        2,
+			Signals:            []edf.SignalHeader{signal, signal},
+		}, nil))
+		require.NoError(t, err)
+
+		_, err = er.SignalByLabel("EEG")
+		require.ErrorContains(t, err, "matching signals [0 1]")
+
+		hdr := er.Header()
+		_, ok := hdr.SignalIndex("EEG")
+		require.False(t, ok)
+	})
+}
+
+// cancelAfterContext is a context that reports cancellation once Err has been called
+// a given number of times, to cancel a read part way through deterministically.
+type cancelAfterContext struct {
+	context.Context
+	calls int
+}
+
+func (ctx *cancelAfterContext) Err() error {
+	if ctx.calls <= 0 {
+		return context.Canceled
+	}
+	ctx.calls--
+	return nil
+}
+
+func TestSignalReaderReadContext(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	flow, err := er.ReadAll(0)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	// Cancelled after two records, the samples read so far are returned.
+	samples := make([]float64, len(flow))
+	n, err := sr.ReadContext(&cancelAfterContext{Context: context.Background(), calls: 2}, samples)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 3000, n)
+	require.Equal(t, flow[:n], samples[:n])
+
+	// Reading resumes from where it was cancelled.
+	n, err = sr.ReadContext(context.Background(), samples[:100])
+	require.NoError(t, err)
+	require.Equal(t, 100, n)
+	require.Equal(t, flow[3000:3100], samples[:100])
+
+	n, err = sr.ReadContext(context.Background(), samples)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, len(flow)-3100, n)
+
+	t.Run("Frame", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		frame, err := er.ReadFrameContext(ctx)
+		require.NoError(t, err)
+		require.Equal(t, flow[:1500], frame[0])
+
+		cancel()
+		_, err = er.ReadFrameContext(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestSignalReaderConcurrent(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	flow, err := er.ReadAll(0)
+	require.NoError(t, err)
+
+	press, err := er.ReadAll(1)
+	require.NoError(t, err)
+
+	// Read both channels at once, in small chunks so the reads interleave.
+	results := make([][]float64, 2)
+	errs := make([]error, 2)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			sr, err := er.Signal(i)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			buf := make([]float64, 37)
+			for {
+				n, err := sr.Read(buf)
+				results[i] = append(results[i], buf[:n]...)
+				if errors.Is(err, io.EOF) {
+					return
+				} else if err != nil {
+					errs[i] = err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+	require.Equal(t, flow, results[0])
+	require.Equal(t, press, results[1])
+}
+
+func TestOpenAt(t *testing.T) {
+	b, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	er, err := edf.Open(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	flow, err := er.ReadAll(0)
+	require.NoError(t, err)
+
+	press, err := er.ReadAll(1)
+	require.NoError(t, err)
+
+	t.Run("File", func(t *testing.T) {
+		f, err := os.Open("testdata/resmed_BRP.edf")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, f.Close())
+		})
+
+		fi, err := f.Stat()
+		require.NoError(t, err)
+
+		er, err := edf.OpenAt(f, fi.Size())
+		require.NoError(t, err)
+		require.Equal(t, 40, er.Header().DataRecords)
+
+		samples, err := er.ReadAll(0)
+		require.NoError(t, err)
+		require.Equal(t, flow, samples)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		er, err := edf.OpenAt(bytes.NewReader(b), int64(len(b)))
+		require.NoError(t, err)
+
+		results := make([][]float64, 2)
+		errs := make([]error, 2)
+
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = er.ReadAll(i)
+			}(i)
+		}
+		wg.Wait()
+
+		require.NoError(t, errs[0])
+		require.NoError(t, errs[1])
+		require.Equal(t, flow, results[0])
+		require.Equal(t, press, results[1])
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		truncated := b[:len(b)-1000]
+
+		er, err := edf.OpenAt(bytes.NewReader(truncated), int64(len(truncated)))
+		require.NoError(t, err)
+
+		_, err = er.ReadAll(3)
+		require.ErrorIs(t, err, edf.ErrTruncatedData)
+	})
+}
+
+func TestReaderErrors(t *testing.T) {
+	b, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	// patched returns a copy of the file with value written at offset.
+	patched := func(offset int, value string) []byte {
+		patched := append([]byte(nil), b...)
+		copy(patched[offset:], value)
+		return patched
+	}
+
+	t.Run("ShortHeader", func(t *testing.T) {
+		_, err := edf.Open(bytes.NewReader(nil))
+		require.ErrorIs(t, err, edf.ErrShortHeader)
+
+		_, err = edf.Open(bytes.NewReader(b[:100]))
+		require.ErrorIs(t, err, edf.ErrShortHeader)
+
+		var fieldErr *edf.FieldError
+		require.ErrorAs(t, err, &fieldErr)
+		require.Equal(t, "RecordingID", fieldErr.Field)
+		require.Equal(t, 88, fieldErr.Offset)
+	})
+
+	t.Run("InvalidDate", func(t *testing.T) {
+		// Without an EDF+ Startdate, the header's start date field is used.
+		_, err := edf.Open(bytes.NewReader(patched(88, strings.Repeat(" ", 80)+"99.99.99")))
+		require.ErrorIs(t, err, edf.ErrInvalidDate)
+
+		var fieldErr *edf.FieldError
+		require.ErrorAs(t, err, &fieldErr)
+		require.Equal(t, "StartDate", fieldErr.Field)
+		require.Equal(t, 168, fieldErr.Offset)
+
+		_, err = edf.Open(bytes.NewReader(patched(176, "noon    ")))
+		require.ErrorIs(t, err, edf.ErrInvalidDate)
+		require.ErrorAs(t, err, &fieldErr)
+		require.Equal(t, "StartTime", fieldErr.Field)
+	})
+
+	t.Run("InvalidField", func(t *testing.T) {
+		_, err := edf.Open(bytes.NewReader(patched(236, "many    ")))
+
+		var fieldErr *edf.FieldError
+		require.ErrorAs(t, err, &fieldErr)
+		require.Equal(t, "DataRecords", fieldErr.Field)
+		require.Equal(t, 236, fieldErr.Offset)
+	})
+
+	t.Run("InvalidSignalField", func(t *testing.T) {
+		// The second signal's digital maximum, after four labels, transducer types,
+		// physical dimensions, physical minimums, physical maximums, digital minimums
+		// and the first signal's digital maximum.
+		corrupt := patched(256+4*(16+80+8+8+8+8)+8, "n/a     ")
+
+		for _, opts := range [][]edf.ReaderOption{nil, {edf.WithStrict()}} {
+			_, err := edf.Open(bytes.NewReader(corrupt), opts...)
+
+			var fieldErr *edf.FieldError
+			require.ErrorAs(t, err, &fieldErr)
+			require.Equal(t, "Signal[1].DigitalMax", fieldErr.Field)
+			require.Equal(t, 776, fieldErr.Offset)
+		}
+	})
+
+	t.Run("SignalIndexOutOfRange", func(t *testing.T) {
+		er, err := edf.Open(bytes.NewReader(b))
+		require.NoError(t, err)
+
+		_, err = er.Signal(4)
+		require.ErrorIs(t, err, edf.ErrSignalIndexOutOfRange)
+	})
+
+	t.Run("TruncatedData", func(t *testing.T) {
+		er, err := edf.Open(bytes.NewReader(b[:len(b)-100]))
+		require.NoError(t, err)
+
+		// Only the end of the last record, holding the last signals, is missing.
+		_, err = er.ReadAll(0)
+		require.NoError(t, err)
+
+		_, err = er.ReadAll(3)
+		require.ErrorIs(t, err, edf.ErrTruncatedData)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
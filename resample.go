@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// resampleState is the state carried between calls to SignalReader.ReadResampled.
+type resampleState struct {
+	targetRate float64   // Output sample rate the state was built for
+	step       float64   // Input samples per output sample
+	taps       []float64 // Anti-aliasing filter taps, a single unit tap when upsampling
+	buf        []float64 // Buffered input samples, starting at input index base
+	base       int       // Input index of buf[0]
+	next       int       // Index of the next output sample
+	eof        bool      // Whether the input has been exhausted
+}
+
+// ReadResampled reads physical values, resampled from the signal's native sample rate to
+// targetRate Hz, into data. Output sample k is taken at time k/targetRate from the start
+// of the signal, by linear interpolation between the two nearest input samples. When
+// downsampling, the input is first low-pass filtered with a Hamming windowed sinc of
+// 8*ceil(rate/targetRate)+1 taps, cut off at half of targetRate, so frequencies above
+// the new Nyquist frequency are attenuated rather than aliased into the output; when
+// upsampling no filter is applied. The filter is extended beyond either end of the
+// signal by repeating its first and last samples, and output stops at the last output
+// time before the end of the signal, giving ceil(n*targetRate/rate) samples for a signal
+// of n samples. Output times falling between the last input sample and the end of the
+// signal repeat the last sample. The target rate must stay the same across calls, and
+// calls shouldn't be interleaved with other reads from the same SignalReader.
+func (sr *SignalReader) ReadResampled(data []float64, targetRate float64) (int, error) {
+	if targetRate <= 0 || math.IsInf(targetRate, 0) || math.IsNaN(targetRate) {
+		return 0, fmt.Errorf("invalid target sample rate %g Hz", targetRate)
+	}
+
+	rs := sr.resample
+	if rs == nil {
+		rate := sr.hdr.SampleRate(sr.signalIndex)
+		if rate <= 0 {
+			return 0, fmt.Errorf("signal has no sample rate")
+		}
+
+		rs = &resampleState{targetRate: targetRate, step: rate / targetRate, taps: []float64{1}}
+		if targetRate < rate {
+			rs.taps = lowpassTaps(0.5*targetRate/rate, 8*int(math.Ceil(rs.step))+1)
+		}
+		sr.resample = rs
+	} else if rs.targetRate != targetRate {
+		return 0, fmt.Errorf("target sample rate changed from %g Hz to %g Hz", rs.targetRate, targetRate)
+	}
+
+	half := (len(rs.taps) - 1) / 2
+	chunk := make([]float64, int(float64(len(data))*rs.step)+len(rs.taps)+1)
+
+	// filtered returns the low-pass filtered input at index i.
+	filtered := func(i int) float64 {
+		var sum float64
+		for j, tap := range rs.taps {
+			k := i + j - half - rs.base
+			if k < 0 {
+				k = 0
+			} else if k >= len(rs.buf) {
+				k = len(rs.buf) - 1
+			}
+			sum += tap * rs.buf[k]
+		}
+		return sum
+	}
+
+	var n int
+	for n < len(data) {
+		x := float64(rs.next) * rs.step
+		i := int(math.Floor(x))
+
+		// Buffer enough input to cover the filter around both neighbouring samples.
+		for !rs.eof && rs.base+len(rs.buf) <= i+1+half {
+			read, err := sr.Read(chunk)
+			rs.buf = append(rs.buf, chunk[:read]...)
+			if errors.Is(err, io.EOF) {
+				rs.eof = true
+			} else if err != nil {
+				return n, err
+			}
+		}
+
+		if i >= rs.base+len(rs.buf) {
+			break
+		}
+
+		frac := x - float64(i)
+		data[n] = (1-frac)*filtered(i) + frac*filtered(i+1)
+		n++
+		rs.next++
+
+		// Drop input no longer needed by the filter, keeping at least the last sample
+		// in case it is repeated to extend the end of the signal.
+		if drop := int(math.Floor(float64(rs.next)*rs.step)) - half - rs.base; drop > 0 {
+			if drop > len(rs.buf)-1 {
+				drop = len(rs.buf) - 1
+			}
+			rs.buf = rs.buf[drop:]
+			rs.base += drop
+		}
+	}
+
+	if n == 0 && len(data) > 0 {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
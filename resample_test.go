@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalReaderReadResampled(t *testing.T) {
+	// signalFile writes a single signal sampled at rate Hz for 4 seconds.
+	signalFile := func(rate int, fn func(t float64) float64) *edf.Reader {
+		records := make([][][]float64, 4)
+		for i := range records {
+			samples := make([]float64, rate)
+			for j := range samples {
+				samples[j] = fn(float64(i) + float64(j)/float64(rate))
+			}
+			records[i] = [][]float64{samples}
+		}
+
+		er, err := edf.Open(writeTestFile(t, edf.Header{
+			Version:            edf.Version0,
+			StartTime:          time.Now(),
+			DataRecordDuration: time.Second,
+			SignalCount:        1,
+			Signals: []edf.SignalHeader{
+				{
+					Label:            "Flow",
+					PhysicalMin:      -250,
+					PhysicalMax:      250,
+					DigitalMin:       -32768,
+					DigitalMax:       32767,
+					SamplesPerRecord: rate,
+				},
+			},
+		}, records))
+		require.NoError(t, err)
+
+		return er
+	}
+
+	// readAll resamples the whole signal in chunks that straddle record boundaries.
+	readAll := func(er *edf.Reader, targetRate float64) []float64 {
+		sr, err := er.Signal(0)
+		require.NoError(t, err)
+
+		var resampled []float64
+		chunk := make([]float64, 37)
+		for {
+			n, err := sr.ReadResampled(chunk, targetRate)
+			resampled = append(resampled, chunk[:n]...)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+		}
+		return resampled
+	}
+
+	t.Run("Upsample", func(t *testing.T) {
+		// A ramp at 25 Hz, interpolated linearly to 100 Hz.
+		er := signalFile(25, func(t float64) float64 { return 25 * t })
+
+		resampled := readAll(er, 100)
+		require.Len(t, resampled, 400)
+
+		for k, v := range resampled {
+			// Beyond the last sample, at 99 of 25 Hz, it is repeated.
+			want := math.Min(float64(k)/4, 99)
+			require.InDelta(t, want, v, 0.01, "sample %d", k)
+		}
+	})
+
+	t.Run("Downsample", func(t *testing.T) {
+		// A 5 Hz sine we want to keep, plus a 120 Hz sine that would alias to 20 Hz if
+		// the 256 Hz signal were naively interpolated at 100 Hz.
+		wanted := func(t float64) float64 {
+			return 100 * math.Sin(2*math.Pi*5*t)
+		}
+		er := signalFile(256, func(t float64) float64 {
+			return wanted(t) + 100*math.Sin(2*math.Pi*120*t)
+		})
+
+		resampled := readAll(er, 100)
+		require.Len(t, resampled, 400)
+
+		// Away from the ends of the recording, only the 5 Hz component remains.
+		for k := 10; k < len(resampled)-10; k++ {
+			require.InDelta(t, wanted(float64(k)/100), resampled[k], 3.0, "sample %d", k)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		sr, err := signalFile(25, math.Sin).Signal(0)
+		require.NoError(t, err)
+
+		_, err = sr.ReadResampled(make([]float64, 1), 0)
+		require.Error(t, err)
+
+		_, err = sr.ReadResampled(make([]float64, 1), 100)
+		require.NoError(t, err)
+
+		// The target rate can't change part way through.
+		_, err = sr.ReadResampled(make([]float64, 1), 50)
+		require.Error(t, err)
+	})
+}
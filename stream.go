@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamReader reads an EDF file strictly sequentially from a source that can't seek,
+// such as a network connection or a pipe, one data record at a time.
+type StreamReader struct {
+	er     *Reader
+	r      *bufio.Reader
+	record int    // Index of the next data record
+	buf    []byte // Scratch space for a raw data record
+}
+
+// OpenStream reads the header of an EDF file from r and returns a StreamReader for its
+// data records. Records are read as they are needed, so the file is never held in
+// memory as a whole. Files whose data records hold no samples are rejected.
+func OpenStream(r io.Reader) (*StreamReader, error) {
+	br := bufio.NewReader(r)
+
+	er := &Reader{byteOrder: binary.LittleEndian}
+	if err := er.readHeader(br); err != nil {
+		return nil, err
+	}
+
+	// With empty records, the end of a file with an unknown number of them could never
+	// be found.
+	if er.hdr.recordSize(er.sampleWidth) == 0 {
+		return nil, fmt.Errorf("data records are empty")
+	}
+
+	// Skip anything between the signal headers and the declared start of the data.
+	if extra := er.hdr.HeaderBytes - 256*(er.hdr.SignalCount+1); extra > 0 {
+		if _, err := br.Discard(extra); err != nil {
+			return nil, fmt.Errorf("error skipping to data records: %w", err)
+		}
+	}
+
+	return &StreamReader{
+		er:  er,
+		r:   br,
+		buf: make([]byte, er.hdr.recordSize(er.sampleWidth)),
+	}, nil
+}
+
+// Header returns a copy of the file's header.
+func (sr *StreamReader) Header() Header {
+	return *sr.er.hdr
+}
+
+// ReadRecord reads the next data record, returning the physical values of every signal
+// in it, indexed by signal. Annotation signals are returned as nil, and the raw values
+// of BDF+ Status signals are returned as is. It returns io.EOF once the number of data
+// records declared in the header has been read, or, if the number is unknown (-1), at
+// the end of the stream.
+func (sr *StreamReader) ReadRecord() ([][]float64, error) {
+	if sr.er.hdr.DataRecords >= 0 && sr.record >= sr.er.hdr.DataRecords {
+		return nil, io.EOF
+	}
+
+	if _, err := io.ReadFull(sr.r, sr.buf); err != nil {
+		if errors.Is(err, io.EOF) && sr.er.hdr.DataRecords < 0 {
+			return nil, io.EOF
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = ErrTruncatedData
+		}
+		return nil, fmt.Errorf("error reading data record %d: %w", sr.record, err)
+	}
+
+	signals := make([][]float64, len(sr.er.hdr.Signals))
+	for i, signal := range sr.er.hdr.Signals {
+		if !signal.IsAnnotations() {
+			signals[i] = make([]float64, signal.SamplesPerRecord)
+		}
+	}
+	sr.er.decodeRecord(sr.buf, signals)
+	sr.record++
+
+	return signals, nil
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenStream(t *testing.T) {
+	data, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	er, err := edf.Open(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	flow, err := er.ReadAll(0)
+	require.NoError(t, err)
+
+	// readStream reads every record from a source that can't seek, returning the
+	// samples of the first signal.
+	readStream := func(t *testing.T, data []byte) []float64 {
+		sr, err := edf.OpenStream(struct{ io.Reader }{bytes.NewReader(data)})
+		require.NoError(t, err)
+		require.Equal(t, "Flow.40ms", sr.Header().Signals[0].Label)
+
+		var samples []float64
+		for {
+			signals, err := sr.ReadRecord()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.Len(t, signals, 4)
+			require.Len(t, signals[3], 1)
+
+			samples = append(samples, signals[0]...)
+		}
+
+		_, err = sr.ReadRecord()
+		require.Equal(t, io.EOF, err)
+
+		return samples
+	}
+
+	require.Equal(t, flow, readStream(t, data))
+
+	t.Run("UnknownRecordCount", func(t *testing.T) {
+		unknown := append([]byte(nil), data...)
+		copy(unknown[236:], "-1      ")
+
+		require.Equal(t, flow, readStream(t, unknown))
+	})
+
+	t.Run("EmptyRecords", func(t *testing.T) {
+		empty := append([]byte(nil), data[:1280]...)
+		copy(empty[236:], "-1      ")
+		// Zero the samples per record of all four signals.
+		for i := 0; i < 4; i++ {
+			copy(empty[1120+8*i:], "0       ")
+		}
+
+		_, err := edf.OpenStream(bytes.NewReader(empty))
+		require.ErrorContains(t, err, "data records are empty")
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		sr, err := edf.OpenStream(bytes.NewReader(data[:len(data)-100]))
+		require.NoError(t, err)
+
+		for i := 0; i < 39; i++ {
+			_, err := sr.ReadRecord()
+			require.NoError(t, err)
+		}
+
+		_, err = sr.ReadRecord()
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
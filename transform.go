@@ -0,0 +1,543 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Transform copies the file to dst with the same header, passing the physical samples
+// of each signal in every data record to fn, which may modify them in place (e.g. to
+// apply a filter or gain). The sample count is always preserved: fn receives exactly
+// SamplesPerRecord samples and can only change their values. Transformed values should
+// remain within the signal's physical range, as the output is encoded using the original
+// calibration. Annotation and Status signals are copied unchanged.
+func (er *Reader) Transform(dst io.WriteSeeker, fn func(signalIndex int, samples []float64)) error {
+	ew, err := Create(dst, *er.hdr)
+	if err != nil {
+		return err
+	}
+
+	samples := make([][]float64, len(er.hdr.Signals))
+	for i, signal := range er.hdr.Signals {
+		samples[i] = make([]float64, signal.SamplesPerRecord)
+	}
+
+	b := make([]byte, er.hdr.recordSize(er.sampleWidth))
+	for record := 0; record < er.dataRecords(); record++ {
+		if err := er.readRecord(record, b); err != nil {
+			return err
+		}
+
+		for i, signal := range er.hdr.Signals {
+			if signal.IsAnnotations() || signal.IsStatus() {
+				continue
+			}
+
+			block := er.hdr.signalBlock(b, i, er.sampleWidth)
+			er.decodeSamples(block, signal, samples[i])
+			fn(i, samples[i])
+			encodeSamples(block, signal, samples[i], er.sampleWidth)
+		}
+
+		if err := ew.writeRawRecord(b); err != nil {
+			return err
+		}
+	}
+
+	return ew.Close()
+}
+
+// RecalibrateOffset copies src to dst, adding a constant physical offset to every sample
+// of the signals in offsets, keyed by signal index, to correct a known DC offset error.
+// The offset is applied by shifting each signal's physical range, so the digital samples
+// are copied unchanged and no precision is lost. The shifted range must still fit the
+// 8 byte header fields, which hold physical values to two decimal places.
+func RecalibrateOffset(dst io.WriteSeeker, src *Reader, offsets map[int]float64) error {
+	hdr := *src.hdr
+	hdr.Signals = append([]SignalHeader(nil), src.hdr.Signals...)
+
+	for i, offset := range offsets {
+		if i < 0 || i >= len(hdr.Signals) {
+			return fmt.Errorf("%w: %d", ErrSignalIndexOutOfRange, i)
+		}
+		if hdr.Signals[i].IsAnnotations() || hdr.Signals[i].IsStatus() {
+			return fmt.Errorf("signal %d has no physical values to recalibrate", i)
+		}
+		if math.IsNaN(offset) || math.IsInf(offset, 0) {
+			return fmt.Errorf("signal %d: invalid offset %v", i, offset)
+		}
+
+		hdr.Signals[i].PhysicalMin += offset
+		hdr.Signals[i].PhysicalMax += offset
+	}
+
+	ew, err := Create(dst, hdr)
+	if err != nil {
+		return fmt.Errorf("error writing recalibrated header: %w", err)
+	}
+
+	b := make([]byte, src.hdr.recordSize(src.sampleWidth))
+	for record := 0; record < src.dataRecords(); record++ {
+		if err := src.readRecord(record, b); err != nil {
+			return err
+		}
+
+		if err := ew.writeRawRecord(b); err != nil {
+			return err
+		}
+	}
+
+	return ew.Close()
+}
+
+// Concat writes the data records of srcs, in order, to dst as a single recording
+// starting at the start time of the first source. The sources must have the same data
+// record duration and identical signals, calibration included, so their records can be
+// copied unchanged; ConcatRescaled relaxes the calibration requirement. They must also
+// have been recorded back to back: each source must start where the previous one ends,
+// within the one second resolution of the header's start time, as the output has no
+// way to represent a gap. The onsets of annotations in EDF Annotations signals, the
+// timekeeping annotations included, are rewritten relative to the start of the first
+// source.
+func Concat(dst io.WriteSeeker, srcs ...*Reader) error {
+	hdr, err := concatHeader(srcs, false)
+	if err != nil {
+		return err
+	}
+
+	ew, err := Create(dst, hdr)
+	if err != nil {
+		return err
+	}
+
+	for n, src := range srcs {
+		// Annotation onsets are relative to the start of their own source.
+		shift := -src.hdr.StartTime.Sub(srcs[0].hdr.StartTime)
+
+		b := make([]byte, src.hdr.recordSize(src.sampleWidth))
+		for record := 0; record < src.dataRecords(); record++ {
+			if err := src.readRecord(record, b); err != nil {
+				return fmt.Errorf("source %d: %w", n, err)
+			}
+
+			if err := src.shiftAnnotations(b, record, shift); err != nil {
+				return fmt.Errorf("source %d: %w", n, err)
+			}
+
+			if err := ew.writeRawRecord(b); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ew.Close()
+}
+
+// ConcatRescaled is like Concat, but the sources only need to share signal labels and
+// sample rates. Where their physical or digital ranges differ, as happens when a device
+// re-autoranges between sessions, each signal is given a common calibration spanning
+// the union of the sources' ranges, and samples from sources with a different
+// calibration are converted to it. The conversion costs precision: a converted sample
+// may be off by up to half a step of the common calibration,
+// (PhysicalMax-PhysicalMin)/(DigitalMax-DigitalMin)/2, which can be coarser than the
+// source's own step when a fine source is widened to cover a coarser one. Samples from sources already matching the common
+// calibration are copied exactly. Status signals must still match exactly.
+func ConcatRescaled(dst io.WriteSeeker, srcs ...*Reader) error {
+	hdr, err := concatHeader(srcs, true)
+	if err != nil {
+		return err
+	}
+
+	ew, err := Create(dst, hdr)
+	if err != nil {
+		return err
+	}
+
+	samples := make([][]float64, len(hdr.Signals))
+	for i, signal := range hdr.Signals {
+		samples[i] = make([]float64, signal.SamplesPerRecord)
+	}
+
+	for n, src := range srcs {
+		// Annotation onsets are relative to the start of their own source.
+		shift := -src.hdr.StartTime.Sub(srcs[0].hdr.StartTime)
+
+		b := make([]byte, src.hdr.recordSize(src.sampleWidth))
+		for record := 0; record < src.dataRecords(); record++ {
+			if err := src.readRecord(record, b); err != nil {
+				return fmt.Errorf("source %d: %w", n, err)
+			}
+
+			if err := src.shiftAnnotations(b, record, shift); err != nil {
+				return fmt.Errorf("source %d: %w", n, err)
+			}
+
+			for i, signal := range src.hdr.Signals {
+				if signal.IsAnnotations() || sameCalibration(signal, hdr.Signals[i]) {
+					continue
+				}
+
+				block := src.hdr.signalBlock(b, i, src.sampleWidth)
+				src.decodeSamples(block, signal, samples[i])
+				encodeSamples(block, hdr.Signals[i], samples[i], src.sampleWidth)
+			}
+
+			if err := ew.writeRawRecord(b); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ew.Close()
+}
+
+// concatHeader checks srcs can be concatenated and returns the header of the result.
+// If rescale is true, signals may differ in calibration, and are given one spanning the
+// union of the sources' physical and digital ranges.
+func concatHeader(srcs []*Reader, rescale bool) (Header, error) {
+	if len(srcs) == 0 {
+		return Header{}, fmt.Errorf("no files to concatenate")
+	}
+
+	first := srcs[0]
+	hdr := *first.hdr
+	hdr.Signals = append([]SignalHeader(nil), first.hdr.Signals...)
+
+	for n, src := range srcs[1:] {
+		n++ // Index of the source in srcs.
+
+		if src.sampleWidth != first.sampleWidth {
+			return Header{}, fmt.Errorf("source %d has %d byte samples, expected %d", n, src.sampleWidth, first.sampleWidth)
+		}
+		if src.hdr.DataRecordDuration != first.hdr.DataRecordDuration {
+			return Header{}, fmt.Errorf("source %d has a data record duration of %s, expected %s", n, src.hdr.DataRecordDuration, first.hdr.DataRecordDuration)
+		}
+		if len(src.hdr.Signals) != len(hdr.Signals) {
+			return Header{}, fmt.Errorf("source %d has %d signals, expected %d", n, len(src.hdr.Signals), len(hdr.Signals))
+		}
+
+		for i, signal := range src.hdr.Signals {
+			common := &hdr.Signals[i]
+			if signal.Label != common.Label {
+				return Header{}, fmt.Errorf("source %d: signal %d is labelled %q, expected %q", n, i, signal.Label, common.Label)
+			}
+			if signal.SamplesPerRecord != common.SamplesPerRecord {
+				return Header{}, fmt.Errorf("source %d: signal %d (%q) has %d samples per record, expected %d", n, i, signal.Label, signal.SamplesPerRecord, common.SamplesPerRecord)
+			}
+			// Annotation signals hold TALs rather than samples, so have no calibration.
+			if signal.IsAnnotations() || sameCalibration(signal, *common) {
+				continue
+			}
+			if !rescale || signal.IsStatus() {
+				return Header{}, fmt.Errorf("source %d: signal %d (%q) has a different calibration", n, i, signal.Label)
+			}
+
+			common.PhysicalMin = math.Min(common.PhysicalMin, signal.PhysicalMin)
+			common.PhysicalMax = math.Max(common.PhysicalMax, signal.PhysicalMax)
+			if signal.DigitalMin < common.DigitalMin {
+				common.DigitalMin = signal.DigitalMin
+			}
+			if signal.DigitalMax > common.DigitalMax {
+				common.DigitalMax = signal.DigitalMax
+			}
+		}
+
+		// Header start times only have a resolution of a second, so allow for the
+		// rounding of a source's start time when it follows on mid-second.
+		prev := srcs[n-1]
+		if prev.dataRecords() < 0 {
+			return Header{}, fmt.Errorf("source %d has an unknown number of data records", n-1)
+		}
+		expected := prev.hdr.StartTime.Add(time.Duration(prev.dataRecords()) * prev.hdr.DataRecordDuration)
+		if gap := src.hdr.StartTime.Sub(expected); gap <= -time.Second || gap >= time.Second {
+			return Header{}, fmt.Errorf("source %d starts at %s, expected %s for it to follow on from source %d",
+				n, src.hdr.StartTime.Format("2006-01-02 15:04:05"), expected.Format("2006-01-02 15:04:05"), n-1)
+		}
+	}
+
+	return hdr, nil
+}
+
+// sameCalibration reports whether two signals map digital to physical values alike.
+func sameCalibration(a, b SignalHeader) bool {
+	return a.PhysicalMin == b.PhysicalMin && a.PhysicalMax == b.PhysicalMax &&
+		a.DigitalMin == b.DigitalMin && a.DigitalMax == b.DigitalMax
+}
+
+// Crop writes the part of src covering [start, end), relative to the start of the
+// recording, to dst. The crop has whole data record granularity: every record that
+// overlaps the range is copied unchanged, so the output may begin up to a record
+// before start and end up to a record after end. The start of each record is taken
+// from its timekeeping annotation in EDF+D files, and from its index otherwise.
+//
+// The start time of the output is moved forward to the first copied record, in whole
+// seconds as the header can't store finer ones, with the Startdate of an EDF+
+// recording identification updated to match. The onsets of annotations in the copied
+// records, timekeeping included, are rewritten relative to the new start time, so in an
+// EDF+D file any sub-second offset of the first record is carried by its timekeeping
+// annotation. Other files, EDF+C included, take record onsets from their indices and so
+// have nowhere to carry such an offset: their crop is widened back to the nearest record
+// starting on a whole second.
+func Crop(dst io.WriteSeeker, src *Reader, start, end time.Duration) error {
+	if start >= end {
+		return fmt.Errorf("invalid crop range [%s, %s)", start, end)
+	}
+
+	timekeeping := src.timekeepingSignal()
+
+	var first int
+	if timekeeping < 0 && start > 0 && src.hdr.DataRecordDuration > 0 {
+		first = int(start / src.hdr.DataRecordDuration)
+		for first > 0 && (time.Duration(first)*src.hdr.DataRecordDuration)%time.Second != 0 {
+			first--
+		}
+	}
+
+	var (
+		ew    *Writer
+		shift time.Duration
+	)
+
+	b := make([]byte, src.hdr.recordSize(src.sampleWidth))
+	for record := first; record < src.dataRecords(); record++ {
+		if timekeeping < 0 && time.Duration(record)*src.hdr.DataRecordDuration >= end {
+			break
+		}
+
+		if err := src.readRecord(record, b); err != nil {
+			return err
+		}
+
+		onset, err := src.recordOnset(b, record, timekeeping)
+		if err != nil {
+			return err
+		}
+		if onset >= end || (timekeeping >= 0 && onset+src.hdr.DataRecordDuration <= start) {
+			continue
+		}
+
+		if ew == nil {
+			shift = onset.Truncate(time.Second)
+			if ew, err = createShifted(dst, src, shift); err != nil {
+				return err
+			}
+		}
+
+		if err := src.shiftAnnotations(b, record, shift); err != nil {
+			return err
+		}
+
+		if err := ew.writeRawRecord(b); err != nil {
+			return err
+		}
+	}
+
+	if ew == nil {
+		return fmt.Errorf("no data records in [%s, %s)", start, end)
+	}
+
+	return ew.Close()
+}
+
+// Split writes src as a series of consecutive files, each covering chunk of the
+// recording, to the destinations returned by open for chunk 0, 1, 2 and so on. Data
+// records aren't split between files, so each file holds chunk's worth of whole
+// records, rounded to the nearest whole number of records (and at least one); the last
+// file holds whatever records remain. Each file's start time and annotations are
+// adjusted as for Crop. Only an EDF+D file can carry a sub-second offset of a chunk's
+// first record, so for other files, EDF+C included, chunks are rounded up to a whole
+// number of seconds' worth of records, e.g. an even number of records for records of
+// half a second. Destinations that implement io.Closer are closed once their chunk has been
+// written, or when an error stops it from being written.
+func Split(src *Reader, chunk time.Duration, open func(i int) (io.WriteSeeker, error)) error {
+	if chunk <= 0 {
+		return fmt.Errorf("invalid chunk duration %s", chunk)
+	}
+	if src.hdr.DataRecordDuration <= 0 {
+		return fmt.Errorf("invalid data record duration %s", src.hdr.DataRecordDuration)
+	}
+
+	recordsPerChunk := int(math.Round(float64(chunk) / float64(src.hdr.DataRecordDuration)))
+	if recordsPerChunk < 1 {
+		recordsPerChunk = 1
+	}
+
+	timekeeping := src.timekeepingSignal()
+	if timekeeping < 0 {
+		aligned := wholeSecondRecords(src.hdr.DataRecordDuration)
+		recordsPerChunk = (recordsPerChunk + aligned - 1) / aligned * aligned
+	}
+
+	var (
+		dst   io.WriteSeeker
+		ew    *Writer
+		shift time.Duration
+	)
+
+	// Close the destination of an unfinished chunk when stopping on an error.
+	defer func() {
+		if closer, ok := dst.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}()
+
+	b := make([]byte, src.hdr.recordSize(src.sampleWidth))
+	for record := 0; record < src.dataRecords(); record++ {
+		if err := src.readRecord(record, b); err != nil {
+			return err
+		}
+
+		if record%recordsPerChunk == 0 {
+			if dst != nil {
+				err := closeChunk(ew, dst)
+				dst = nil
+				if err != nil {
+					return fmt.Errorf("chunk %d: %w", record/recordsPerChunk-1, err)
+				}
+			}
+
+			onset, err := src.recordOnset(b, record, timekeeping)
+			if err != nil {
+				return err
+			}
+			shift = onset.Truncate(time.Second)
+
+			i := record / recordsPerChunk
+			if dst, err = open(i); err != nil {
+				return fmt.Errorf("error opening chunk %d: %w", i, err)
+			}
+			if ew, err = createShifted(dst, src, shift); err != nil {
+				return fmt.Errorf("chunk %d: %w", i, err)
+			}
+		}
+
+		if err := src.shiftAnnotations(b, record, shift); err != nil {
+			return err
+		}
+
+		if err := ew.writeRawRecord(b); err != nil {
+			return err
+		}
+	}
+
+	if dst != nil {
+		err := closeChunk(ew, dst)
+		dst = nil
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", (src.dataRecords()-1)/recordsPerChunk, err)
+		}
+	}
+
+	return nil
+}
+
+// closeChunk finishes writing a chunk written by Split, closing its destination if it
+// is an io.Closer, even if finishing the chunk fails.
+func closeChunk(ew *Writer, dst io.WriteSeeker) error {
+	err := ew.Close()
+
+	if closer, ok := dst.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// wholeSecondRecords returns the smallest number of data records of the given duration
+// that together span a whole number of seconds.
+func wholeSecondRecords(duration time.Duration) int {
+	a, b := int64(duration), int64(time.Second)
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return int(int64(time.Second) / a)
+}
+
+// createShifted starts writing a copy of src to dst whose start time is moved forward
+// by shift, with the Startdate of an EDF+ recording identification updated to match.
+func createShifted(dst io.WriteSeeker, src *Reader, shift time.Duration) (*Writer, error) {
+	hdr := *src.hdr
+	hdr.StartTime = hdr.StartTime.Add(shift)
+	hdr.RecordingID = withStartdate(hdr.RecordingID, hdr.StartTime)
+
+	return Create(dst, hdr)
+}
+
+// timekeepingSignal returns the index of the annotation signal whose timekeeping
+// annotations give the onsets of the data records of an EDF+D file, or -1 if the onsets
+// follow from the record indices.
+func (er *Reader) timekeepingSignal() int {
+	if er.hdr.Type != EDFPlusDiscontinuous {
+		return -1
+	}
+
+	for i, signal := range er.hdr.Signals {
+		if signal.IsAnnotations() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// recordOnset returns the onset of a raw data record, relative to the start of the
+// recording, from the timekeeping annotation of the given signal (see
+// timekeepingSignal), or from the record's index if it is -1.
+func (er *Reader) recordOnset(b []byte, record, timekeeping int) (time.Duration, error) {
+	if timekeeping < 0 {
+		return time.Duration(record) * er.hdr.DataRecordDuration, nil
+	}
+
+	onset, err := parseTimekeeping(er.hdr.signalBlock(b, timekeeping, er.sampleWidth))
+	if err != nil {
+		return 0, fmt.Errorf("error parsing annotations in record %d: %w", record, err)
+	}
+
+	return onset, nil
+}
+
+// shiftAnnotations rewrites the onsets of the annotations in a raw data record to be
+// shift earlier.
+func (er *Reader) shiftAnnotations(b []byte, record int, shift time.Duration) error {
+	if shift == 0 {
+		return nil
+	}
+
+	for i, signal := range er.hdr.Signals {
+		if !signal.IsAnnotations() {
+			continue
+		}
+		if err := shiftTALs(er.hdr.signalBlock(b, i, er.sampleWidth), shift); err != nil {
+			return fmt.Errorf("error rewriting annotations in record %d: %w", record, err)
+		}
+	}
+
+	return nil
+}
+
+// withStartdate returns an EDF+ recording identification with its Startdate subfield
+// set to the date of t. Identifications that don't follow EDF+, or whose start date is
+// unknown ("X"), are returned unchanged.
+func withStartdate(recordingID string, t time.Time) string {
+	fields := strings.Fields(recordingID)
+	if len(fields) < 2 || fields[0] != "Startdate" || fields[1] == "X" {
+		return recordingID
+	}
+
+	fields[1] = strings.ToUpper(t.Format("02-Jan-2006"))
+	return strings.Join(fields, " ")
+}
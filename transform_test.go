@@ -0,0 +1,641 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderTransform(t *testing.T) {
+	src, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, src.Close())
+	})
+
+	er, err := edf.Open(src)
+	require.NoError(t, err)
+
+	// Halve the flow signal, leaving the others untouched.
+	dst := createTestFile(t)
+	err = er.Transform(dst, func(signalIndex int, samples []float64) {
+		if signalIndex != 0 {
+			return
+		}
+		for i := range samples {
+			samples[i] *= 0.5
+		}
+	})
+	require.NoError(t, err)
+
+	_, err = dst.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	transformed, err := edf.Open(dst)
+	require.NoError(t, err)
+
+	for signalIndex, scale := range []float64{0.5, 1.0} {
+		sr, err := er.Signal(signalIndex)
+		require.NoError(t, err)
+
+		original := make([]float64, 60000)
+		_, err = sr.Read(original)
+		require.NoError(t, err)
+
+		sr, err = transformed.Signal(signalIndex)
+		require.NoError(t, err)
+
+		samples := make([]float64, 60000)
+		_, err = sr.Read(samples)
+		require.NoError(t, err)
+
+		resolution := transformed.SignalMetadata()[signalIndex].Resolution
+		for i := range samples {
+			require.InDelta(t, original[i]*scale, samples[i], resolution+1e-9)
+		}
+
+		_, err = sr.Read(samples)
+		require.Equal(t, io.EOF, err)
+	}
+}
+
+func TestRecalibrateOffset(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "ECG",
+				PhysicalDimension: "mV",
+				PhysicalMin:       -5,
+				PhysicalMax:       5,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  4,
+			},
+			{
+				Label:             "Resp",
+				PhysicalDimension: "mV",
+				PhysicalMin:       -5,
+				PhysicalMax:       5,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  4,
+			},
+		},
+	}
+
+	samples := []float64{-4, -1, 1, 4}
+	src, err := edf.Open(writeTestFile(t, hdr, [][][]float64{{samples, samples}}))
+	require.NoError(t, err)
+
+	original, err := src.ReadAll(0)
+	require.NoError(t, err)
+
+	dst := createTestFile(t)
+	require.NoError(t, edf.RecalibrateOffset(dst, src, map[int]float64{0: -0.25}))
+
+	_, err = dst.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(dst)
+	require.NoError(t, err)
+
+	require.Equal(t, -5.25, er.Header().Signals[0].PhysicalMin)
+	require.Equal(t, 4.75, er.Header().Signals[0].PhysicalMax)
+
+	recalibrated, err := er.ReadAll(0)
+	require.NoError(t, err)
+	for i := range original {
+		require.InDelta(t, original[i]-0.25, recalibrated[i], 1e-9)
+	}
+
+	// Other signals are untouched.
+	untouched, err := er.ReadAll(1)
+	require.NoError(t, err)
+	require.Equal(t, original, untouched)
+
+	// The shifted range has to fit in the header.
+	err = edf.RecalibrateOffset(createTestFile(t), src, map[int]float64{1: 1e8})
+	require.ErrorContains(t, err, "too long")
+}
+
+func TestConcat(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 12, 12, 22, 0, 0, 0, time.UTC),
+		DataRecordDuration: 30 * time.Minute,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "SpO2",
+				PhysicalMin:      0,
+				PhysicalMax:      100,
+				DigitalMin:       0,
+				DigitalMax:       1000,
+				SamplesPerRecord: 2,
+			},
+		},
+	}
+
+	// Two hourly files recorded back to back.
+	first, err := edf.Open(writeTestFile(t, hdr, [][][]float64{{{97, 96}}, {{95, 94}}}))
+	require.NoError(t, err)
+
+	hdr.StartTime = hdr.StartTime.Add(time.Hour)
+	second, err := edf.Open(writeTestFile(t, hdr, [][][]float64{{{93, 92}}, {{91, 90}}}))
+	require.NoError(t, err)
+
+	dst := createTestFile(t)
+	require.NoError(t, edf.Concat(dst, first, second))
+
+	_, err = dst.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(dst)
+	require.NoError(t, err)
+	require.Equal(t, 4, er.Header().DataRecords)
+	require.True(t, first.Header().StartTime.Equal(er.Header().StartTime))
+
+	samples, err := er.ReadAll(0)
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{97, 96, 95, 94, 93, 92, 91, 90}, samples, 0.1)
+
+	// Files that don't follow on from one another can't be concatenated.
+	require.ErrorContains(t, edf.Concat(createTestFile(t), second, first), "to follow on from source 0")
+
+	t.Run("Annotations", func(t *testing.T) {
+		hdr := annotatedHeader
+		hdr.Type = edf.EDFPlusContinuous
+
+		first, err := edf.Open(writeRawTestFile(t, hdr, [][]byte{
+			annotatedRecord("+0\x14\x14\x00", "+0.5\x14Lights off\x14\x00"),
+			annotatedRecord("+1\x14\x14\x00"),
+		}))
+		require.NoError(t, err)
+
+		hdr.StartTime = hdr.StartTime.Add(2 * time.Second)
+		second, err := edf.Open(writeRawTestFile(t, hdr, [][]byte{
+			annotatedRecord("+0\x14\x14\x00", "+0.5\x14Arousal\x14\x00"),
+		}))
+		require.NoError(t, err)
+
+		dst := createTestFile(t)
+		require.NoError(t, edf.Concat(dst, first, second))
+
+		_, err = dst.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		er, err := edf.Open(dst)
+		require.NoError(t, err)
+		require.Equal(t, 3, er.Header().DataRecords)
+		require.NoError(t, er.ValidateAnnotations())
+
+		// The second file's onsets are moved on by its start relative to the first's.
+		annotations, err := er.Annotations()
+		require.NoError(t, err)
+		require.Equal(t, []edf.Annotation{
+			{Onset: 500 * time.Millisecond, Texts: []string{"Lights off"}},
+			{Onset: 2500 * time.Millisecond, Texts: []string{"Arousal"}},
+		}, annotations)
+	})
+}
+
+func TestConcatRescaled(t *testing.T) {
+	// session returns a single channel header with the given physical range.
+	session := func(start time.Time, pmin, pmax float64) edf.Header {
+		return edf.Header{
+			Version:            edf.Version0,
+			StartTime:          start,
+			DataRecordDuration: time.Second,
+			SignalCount:        1,
+			Signals: []edf.SignalHeader{
+				{
+					Label:             "Flow",
+					PhysicalDimension: "L/s",
+					PhysicalMin:       pmin,
+					PhysicalMax:       pmax,
+					DigitalMin:        -2048,
+					DigitalMax:        2047,
+					SamplesPerRecord:  4,
+				},
+			},
+		}
+	}
+
+	start := time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC)
+
+	// The device re-autoranged to a wider range for the second session.
+	first, err := edf.Open(writeTestFile(t, session(start, -1, 1), [][][]float64{
+		{{-0.5, 0, 0.5, 0.9}},
+	}))
+	require.NoError(t, err)
+
+	second, err := edf.Open(writeTestFile(t, session(start.Add(time.Second), -2, 2), [][][]float64{
+		{{-1.5, 1.5, 0.25, -0.25}},
+		{{1.9, -1.9, 1, -1}},
+	}))
+	require.NoError(t, err)
+
+	third, err := edf.Open(writeTestFile(t, session(start.Add(3*time.Second), -2, 2), [][][]float64{
+		{{0, 0, 0, 0}},
+		{{1, 1, 1, 1}},
+	}))
+	require.NoError(t, err)
+
+	// A plain concatenation copies matching files as is.
+	dst := createTestFile(t)
+	require.NoError(t, edf.Concat(dst, second, third))
+
+	_, err = dst.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(dst)
+	require.NoError(t, err)
+	require.Equal(t, 4, er.Header().DataRecords)
+
+	// But requires matching calibrations.
+	require.ErrorContains(t, edf.Concat(createTestFile(t), first, second), "different calibration")
+
+	dst = createTestFile(t)
+	require.NoError(t, edf.ConcatRescaled(dst, first, second))
+
+	_, err = dst.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err = edf.Open(dst)
+	require.NoError(t, err)
+
+	require.Equal(t, start, er.Header().StartTime)
+	require.Equal(t, 3, er.Header().DataRecords)
+	require.Equal(t, -2.0, er.Header().Signals[0].PhysicalMin)
+	require.Equal(t, 2.0, er.Header().Signals[0].PhysicalMax)
+
+	samples, err := er.ReadAll(0)
+	require.NoError(t, err)
+
+	want := []float64{-0.5, 0, 0.5, 0.9, -1.5, 1.5, 0.25, -0.25, 1.9, -1.9, 1, -1}
+	require.Len(t, samples, len(want))
+
+	// Samples are within the precision of the source plus that of the common calibration.
+	tolerance := er.SignalMetadata()[0].Resolution + 2.0/4095
+	for i := range want {
+		require.InDelta(t, want[i], samples[i], tolerance, "sample %d", i)
+	}
+
+	// A coarse 12-bit source followed by a fine 16-bit one: the common calibration
+	// spans +-500 over the 16-bit digital range, coarser than the second source's.
+	coarse := session(start, -500, 500)
+	fine := session(start.Add(time.Second), -200, 200)
+	fine.Signals[0].DigitalMin = math.MinInt16
+	fine.Signals[0].DigitalMax = math.MaxInt16
+
+	var sources []*edf.Reader
+	var values []float64
+	for i, hdr := range []edf.Header{coarse, fine} {
+		src, err := edf.Open(writeTestFile(t, hdr, [][][]float64{
+			{{-123.456, 0.01, 77.7, 199.99}},
+		}))
+		require.NoError(t, err, "source %d", i)
+
+		stored, err := src.ReadAll(0)
+		require.NoError(t, err)
+
+		sources = append(sources, src)
+		values = append(values, stored...)
+	}
+
+	dst = createTestFile(t)
+	require.NoError(t, edf.ConcatRescaled(dst, sources...))
+
+	_, err = dst.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err = edf.Open(dst)
+	require.NoError(t, err)
+
+	common := er.Header().Signals[0]
+	require.Equal(t, -500.0, common.PhysicalMin)
+	require.Equal(t, 500.0, common.PhysicalMax)
+	require.Equal(t, math.MinInt16, common.DigitalMin)
+	require.Equal(t, math.MaxInt16, common.DigitalMax)
+
+	samples, err = er.ReadAll(0)
+	require.NoError(t, err)
+	require.Len(t, samples, len(values))
+
+	// Each sample is within half a step of the common calibration of the value stored
+	// in its source.
+	tolerance = (common.PhysicalMax - common.PhysicalMin) / float64(common.DigitalMax-common.DigitalMin) / 2
+	for i := range values {
+		require.InDelta(t, values[i], samples[i], tolerance+1e-9, "sample %d", i)
+	}
+
+	// Labels still have to match.
+	other := session(start, -1, 1)
+	other.Signals[0].Label = "Pressure"
+	mismatched, err := edf.Open(writeTestFile(t, other, nil))
+	require.NoError(t, err)
+	require.ErrorContains(t, edf.ConcatRescaled(createTestFile(t), first, mismatched), "labelled")
+}
+
+func TestCrop(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	src, err := edf.Open(f)
+	require.NoError(t, err)
+
+	srcHdr := src.Header()
+	recordDuration := srcHdr.DataRecordDuration
+
+	// Partially overlapping the second and third records.
+	dst := createTestFile(t)
+	require.NoError(t, edf.Crop(dst, src, recordDuration+recordDuration/2, 2*recordDuration+recordDuration/2))
+
+	_, err = dst.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	cropped, err := edf.Open(dst)
+	require.NoError(t, err)
+
+	hdr := cropped.Header()
+	require.Equal(t, 2, hdr.DataRecords)
+	require.True(t, srcHdr.StartTime.Add(recordDuration).Equal(hdr.StartTime))
+
+	for i, signal := range srcHdr.Signals {
+		samples, err := src.ReadAll(i)
+		require.NoError(t, err)
+
+		croppedSamples, err := cropped.ReadAll(i)
+		require.NoError(t, err)
+		require.Equal(t, samples[signal.SamplesPerRecord:3*signal.SamplesPerRecord], croppedSamples)
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		require.Error(t, edf.Crop(createTestFile(t), src, 40*recordDuration, 41*recordDuration))
+	})
+
+	t.Run("SubSecondRecords", func(t *testing.T) {
+		hdr := edf.Header{
+			Version:            edf.Version0,
+			StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+			DataRecordDuration: 500 * time.Millisecond,
+			SignalCount:        1,
+			Signals: []edf.SignalHeader{
+				{
+					Label:            "Flow",
+					PhysicalMin:      0,
+					PhysicalMax:      100,
+					DigitalMin:       0,
+					DigitalMax:       100,
+					SamplesPerRecord: 1,
+				},
+			},
+		}
+
+		records := make([][][]float64, 30)
+		for i := range records {
+			records[i] = [][]float64{{float64(i)}}
+		}
+
+		src, err := edf.Open(writeTestFile(t, hdr, records))
+		require.NoError(t, err)
+
+		// Without an annotations signal, the half second offset of the record at 10.5s
+		// can't be stored, so the crop is widened back to the record at 10s.
+		dst := createTestFile(t)
+		require.NoError(t, edf.Crop(dst, src, 10500*time.Millisecond, 12*time.Second))
+
+		_, err = dst.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		cropped, err := edf.Open(dst)
+		require.NoError(t, err)
+		require.True(t, hdr.StartTime.Add(10*time.Second).Equal(cropped.Header().StartTime))
+
+		samples, err := cropped.ReadAll(0)
+		require.NoError(t, err)
+		require.Equal(t, []float64{20, 21, 22, 23}, samples)
+
+		// An EDF+C file takes its record onsets from their indices too, so it is
+		// widened alike despite having an annotations signal.
+		plus := annotatedHeader
+		plus.Type = edf.EDFPlusContinuous
+		plus.DataRecordDuration = 500 * time.Millisecond
+
+		raw := make([][]byte, 30)
+		for i := range raw {
+			raw[i] = annotatedRecord(fmt.Sprintf("%+g\x14\x14\x00", float64(i)/2))
+		}
+		raw[21] = annotatedRecord("+10.5\x14\x14\x00", "+10.75\x14Arousal\x14\x00")
+
+		src, err = edf.Open(writeRawTestFile(t, plus, raw))
+		require.NoError(t, err)
+
+		dst = createTestFile(t)
+		require.NoError(t, edf.Crop(dst, src, 10500*time.Millisecond, 12*time.Second))
+
+		_, err = dst.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		cropped, err = edf.Open(dst)
+		require.NoError(t, err)
+		require.True(t, plus.StartTime.Add(10*time.Second).Equal(cropped.Header().StartTime))
+		require.Equal(t, 4, cropped.Header().DataRecords)
+
+		annotations, err := cropped.Annotations()
+		require.NoError(t, err)
+		require.Equal(t, []edf.Annotation{
+			{Onset: 750 * time.Millisecond, Texts: []string{"Arousal"}},
+		}, annotations)
+	})
+
+	t.Run("Discontinuous", func(t *testing.T) {
+		hdr := annotatedHeader
+		hdr.Type = edf.EDFPlusDiscontinuous
+		hdr.RecordingID = "Startdate 12-DEC-2024 X X X"
+
+		src, err := edf.Open(writeRawTestFile(t, hdr, [][]byte{
+			annotatedRecord("+0\x14\x14\x00", "+0.5\x14Lights off\x14\x00"),
+			annotatedRecord("+10\x14\x14\x00"),
+			annotatedRecord("+5400.5\x14\x14\x00", "+5401\x150.5\x14Arousal\x14\x00"),
+		}))
+		require.NoError(t, err)
+
+		dst := createTestFile(t)
+		require.NoError(t, edf.Crop(dst, src, 5000*time.Second, 6000*time.Second))
+
+		_, err = dst.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		cropped, err := edf.Open(dst)
+		require.NoError(t, err)
+
+		croppedHdr := cropped.Header()
+		require.Equal(t, 1, croppedHdr.DataRecords)
+		require.Equal(t, edf.EDFPlusDiscontinuous, croppedHdr.Type)
+		require.True(t, time.Date(2024, 12, 13, 0, 0, 0, 0, time.UTC).Equal(croppedHdr.StartTime))
+		require.Equal(t, "Startdate 13-DEC-2024 X X X", strings.TrimSpace(croppedHdr.RecordingID))
+
+		annotations, err := cropped.Annotations()
+		require.NoError(t, err)
+		require.Equal(t, []edf.Annotation{
+			{Onset: time.Second, Duration: 500 * time.Millisecond, Texts: []string{"Arousal"}},
+		}, annotations)
+
+		timeline, err := cropped.AnnotationTimeline()
+		require.NoError(t, err)
+		require.True(t, time.Date(2024, 12, 13, 0, 0, 1, 0, time.UTC).Equal(timeline[0].Time))
+	})
+}
+
+func TestSplit(t *testing.T) {
+	f, err := os.Open("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	src, err := edf.Open(f)
+	require.NoError(t, err)
+
+	srcHdr := src.Header()
+	recordDuration := srcHdr.DataRecordDuration
+
+	// Rounded to 15 records per chunk, leaving 10 records for the last chunk.
+	dir := t.TempDir()
+	var names []string
+	require.NoError(t, edf.Split(src, 14*recordDuration+recordDuration*3/5, func(i int) (io.WriteSeeker, error) {
+		require.Equal(t, len(names), i)
+		name := filepath.Join(dir, fmt.Sprintf("chunk%d.edf", i))
+		names = append(names, name)
+		return os.Create(name)
+	}))
+	require.Len(t, names, 3)
+
+	flow, err := src.ReadAll(0)
+	require.NoError(t, err)
+
+	var records int
+	var joined []float64
+	for i, name := range names {
+		chunk, err := os.Open(name)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, chunk.Close())
+		})
+
+		er, err := edf.Open(chunk)
+		require.NoError(t, err)
+
+		hdr := er.Header()
+		require.Equal(t, []int{15, 15, 10}[i], hdr.DataRecords)
+		require.True(t, srcHdr.StartTime.Add(time.Duration(records)*recordDuration).Equal(hdr.StartTime))
+		records += hdr.DataRecords
+
+		samples, err := er.ReadAll(0)
+		require.NoError(t, err)
+		joined = append(joined, samples...)
+	}
+
+	require.Equal(t, flow, joined)
+
+	t.Run("SubSecondRecords", func(t *testing.T) {
+		hdr := edf.Header{
+			Version:            edf.Version0,
+			StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+			DataRecordDuration: 500 * time.Millisecond,
+			SignalCount:        1,
+			Signals: []edf.SignalHeader{
+				{
+					Label:            "Flow",
+					PhysicalMin:      0,
+					PhysicalMax:      100,
+					DigitalMin:       0,
+					DigitalMax:       100,
+					SamplesPerRecord: 1,
+				},
+			},
+		}
+
+		records := make([][][]float64, 10)
+		for i := range records {
+			records[i] = [][]float64{{float64(i)}}
+		}
+
+		src, err := edf.Open(writeTestFile(t, hdr, records))
+		require.NoError(t, err)
+
+		// Without an annotations signal, chunks of 3 records would start on a half
+		// second, so they are rounded up to 4.
+		dir := t.TempDir()
+		var names []string
+		require.NoError(t, edf.Split(src, 1500*time.Millisecond, func(i int) (io.WriteSeeker, error) {
+			name := filepath.Join(dir, fmt.Sprintf("chunk%d.edf", i))
+			names = append(names, name)
+			return os.Create(name)
+		}))
+		require.Len(t, names, 3)
+
+		for i, name := range names {
+			b, err := os.ReadFile(name)
+			require.NoError(t, err)
+
+			er, err := edf.Open(bytes.NewReader(b))
+			require.NoError(t, err)
+			require.True(t, hdr.StartTime.Add(time.Duration(2*i)*time.Second).Equal(er.Header().StartTime))
+
+			samples, err := er.ReadAll(0)
+			require.NoError(t, err)
+			require.Equal(t, float64(4*i), samples[0])
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		b, err := os.ReadFile("testdata/resmed_BRP.edf")
+		require.NoError(t, err)
+
+		// The last data record is truncated, failing the last chunk partway through.
+		src, err := edf.Open(bytes.NewReader(b[:len(b)-100]))
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		var files []*os.File
+		err = edf.Split(src, 15*recordDuration, func(i int) (io.WriteSeeker, error) {
+			f, err := os.Create(filepath.Join(dir, fmt.Sprintf("chunk%d.edf", i)))
+			files = append(files, f)
+			return f, err
+		})
+		require.ErrorIs(t, err, edf.ErrTruncatedData)
+		require.Len(t, files, 3)
+
+		// Every destination was closed, including that of the unfinished chunk.
+		for _, f := range files {
+			require.ErrorIs(t, f.Close(), os.ErrClosed)
+		}
+	})
+}
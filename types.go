@@ -9,15 +9,60 @@
 
 package edf
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
 
 type Version string
 
 const (
 	// Version0 represents the version of the EDF standard.
 	Version0 Version = "0"
+	// VersionBDF identifies a BioSemi BDF file, which stores 24-bit samples.
+	VersionBDF Version = "\xffBIOSEMI"
+)
+
+// AnnotationsLabel is the label of the reserved EDF+ signal used to store annotations.
+const AnnotationsLabel = "EDF Annotations"
+
+// StatusLabel is the label of the BDF+ signal carrying trigger and status bits.
+const StatusLabel = "Status"
+
+// Bits of a BDF+ Status signal value, above the 16 trigger input bits.
+const (
+	StatusEpochStart = 1 << 16 // High for the first sample of a new epoch
+	StatusCMSInRange = 1 << 20 // High while the CMS electrode is within range
+	StatusBatteryLow = 1 << 22 // High while the amplifier battery is low
+)
+
+// EDFType distinguishes plain EDF files from continuous and discontinuous EDF+ files, as
+// declared by the continuity marker at the start of the reserved header field.
+type EDFType int
+
+const (
+	// Plain is a plain EDF (or BDF) file, without an EDF+ continuity marker.
+	Plain EDFType = iota
+	// EDFPlusContinuous is an EDF+C file, whose data records are contiguous in time.
+	EDFPlusContinuous
+	// EDFPlusDiscontinuous is an EDF+D file, which may have gaps between data records.
+	// The onset of each record is given by its timekeeping annotation.
+	EDFPlusDiscontinuous
 )
 
+func (t EDFType) String() string {
+	switch t {
+	case EDFPlusContinuous:
+		return "EDF+C"
+	case EDFPlusDiscontinuous:
+		return "EDF+D"
+	default:
+		return "EDF"
+	}
+}
+
 // Header represents the EDF/EDF+ file header.
 type Header struct {
 	Version            Version        // Version of the EDF standard.
@@ -26,10 +71,51 @@ type Header struct {
 	StartTime          time.Time      // Start date of the recording
 	HeaderBytes        int            // Number of bytes in the header
 	Reserved           string         // Reserved for future use
+	Type               EDFType        // Whether the file is plain EDF, or continuous or discontinuous EDF+
+	VendorTag          string         // Identifier stamped by the writing tool, stored in the reserved field
 	DataRecordDuration time.Duration  // Duration of a single data record in seconds
 	DataRecords        int            // Number of data records, -1 if unknown
 	SignalCount        int            // Number of signals in each data record
 	Signals            []SignalHeader // Details of each signal
+	BytesPerSample     int            // Size of a sample in bytes: 2 for EDF, 3 for BDF
+}
+
+// String returns a one line summary of the header, suitable for logging.
+func (h Header) String() string {
+	dialect := h.Type.String()
+	if h.Version == VersionBDF {
+		dialect = "BDF" + strings.TrimPrefix(dialect, "EDF")
+	}
+
+	records := "unknown number of records"
+	if total, err := h.TotalDuration(); err == nil {
+		records = fmt.Sprintf("%d records (%s)", h.DataRecords, total)
+	}
+
+	return fmt.Sprintf("%s version %q, patient %q, started %s, %d signals, %s of %s each",
+		dialect, h.Version, h.PatientID, h.StartTime.Format("2006-01-02 15:04:05"), h.SignalCount, records, h.DataRecordDuration)
+}
+
+// TotalDuration returns the duration of the recording, its number of data records
+// times the data record duration. If the number of data records is unknown (-1), it
+// returns 0 and ErrUnknownRecordCount.
+func (h *Header) TotalDuration() (time.Duration, error) {
+	if h.DataRecords < 0 {
+		return 0, ErrUnknownRecordCount
+	}
+	return time.Duration(h.DataRecords) * h.DataRecordDuration, nil
+}
+
+// EndTime returns the time at which the recording ends, its start time plus
+// TotalDuration. If the number of data records is unknown (-1), it returns the start
+// time and ErrUnknownRecordCount. The gaps between the data records of an EDF+D file
+// aren't recorded in the header, so for those Reader.EndTime should be used instead.
+func (h *Header) EndTime() (time.Time, error) {
+	total, err := h.TotalDuration()
+	if err != nil {
+		return h.StartTime, err
+	}
+	return h.StartTime.Add(total), nil
 }
 
 // SignalHeader represents the characteristics of each signal in the EDF/EDF+ file.
@@ -45,3 +131,167 @@ type SignalHeader struct {
 	SamplesPerRecord  int     // Number of samples in each data record for this signal
 	Reserved          string  // Reserved for future use
 }
+
+// NewSignal returns the header of a signal with the given label, physical dimension
+// and physical range, sampled at sampleRate Hz in data records of recordDuration. The
+// digital range spans the full 16-bit sample range and the transducer type and
+// prefiltering are left blank. The sample rate must give a whole number of samples
+// per data record.
+func NewSignal(label, unit string, pmin, pmax float64, sampleRate float64, recordDuration time.Duration) (SignalHeader, error) {
+	if pmin == pmax {
+		return SignalHeader{}, fmt.Errorf("physical range [%g, %g] is empty", pmin, pmax)
+	}
+
+	samples := sampleRate * recordDuration.Seconds()
+	samplesPerRecord := math.Round(samples)
+	if samplesPerRecord <= 0 || math.Abs(samples-samplesPerRecord) > 1e-9 {
+		return SignalHeader{}, fmt.Errorf("sample rate %g Hz does not give a whole number of samples per %s data record", sampleRate, recordDuration)
+	}
+
+	return SignalHeader{
+		Label:             label,
+		PhysicalDimension: unit,
+		PhysicalMin:       pmin,
+		PhysicalMax:       pmax,
+		DigitalMin:        math.MinInt16,
+		DigitalMax:        math.MaxInt16,
+		SamplesPerRecord:  int(samplesPerRecord),
+	}, nil
+}
+
+// SampleRate returns the signal's sample rate in samples per second, given the data
+// record duration of the file, or 0 if the duration isn't positive. The duration is
+// converted exactly, so fractional durations such as 0.1 s give exact rates.
+func (s SignalHeader) SampleRate(recordDuration time.Duration) float64 {
+	if recordDuration <= 0 {
+		return 0
+	}
+	return float64(s.SamplesPerRecord) * float64(time.Second) / float64(recordDuration)
+}
+
+// IsAnnotations reports whether the signal is an EDF+ annotations signal rather than
+// a sampled data signal.
+func (s SignalHeader) IsAnnotations() bool {
+	return s.Label == AnnotationsLabel
+}
+
+// IsStatus reports whether the signal is a BDF+ Status signal, whose samples are
+// bit-packed trigger and status values rather than calibrated measurements.
+func (s SignalHeader) IsStatus() bool {
+	return s.Label == StatusLabel
+}
+
+// TriggerBits returns the trigger inputs of a Status signal value, which occupy the
+// low 16 bits. Bit 0 corresponds to trigger input 1. The remaining high bits of a
+// 24-bit BDF+ value carry amplifier status (see StatusEpochStart and friends).
+func TriggerBits(status int32) uint16 {
+	return uint16(status & 0xFFFF)
+}
+
+// SignalIndex returns the index of the signal with the given label, compared with
+// surrounding spaces trimmed. It returns false if no signal, or more than one, has the
+// label.
+func (h *Header) SignalIndex(label string) (int, bool) {
+	indices := h.signalIndices(label, false)
+	if len(indices) != 1 {
+		return -1, false
+	}
+	return indices[0], true
+}
+
+// signalIndices returns the indices of the signals with the given label, optionally
+// compared regardless of case.
+func (h *Header) signalIndices(label string, foldCase bool) []int {
+	label = strings.TrimSpace(label)
+
+	var indices []int
+	for i, signal := range h.Signals {
+		candidate := strings.TrimSpace(signal.Label)
+		if candidate == label || (foldCase && strings.EqualFold(candidate, label)) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// Times returns the times of the first n samples of a signal, assuming the data records
+// follow on from one another, as in plain EDF and EDF+C files. It returns nil if n isn't
+// positive, the signal index is out of range or the signal has no samples. The gaps between the
+// records of an EDF+D file aren't recorded in the header, so for those
+// SignalReader.TimeAt should be used instead.
+func (h *Header) Times(signalIndex int, n int) []time.Time {
+	if n <= 0 || signalIndex < 0 || signalIndex >= len(h.Signals) || h.Signals[signalIndex].SamplesPerRecord <= 0 {
+		return nil
+	}
+
+	times := make([]time.Time, n)
+	for i := range times {
+		times[i] = h.StartTime.Add(h.sampleOffset(signalIndex, int64(i)))
+	}
+	return times
+}
+
+// sampleOffset returns the time of a sample of a signal relative to the start of the
+// recording, assuming the data records follow on from one another. It is computed in
+// whole nanoseconds from the record and the sample's position within it, so there is
+// no accumulated rounding error.
+func (h *Header) sampleOffset(signalIndex int, sampleIndex int64) time.Duration {
+	samplesPerRecord := int64(h.Signals[signalIndex].SamplesPerRecord)
+	record, sample := sampleIndex/samplesPerRecord, sampleIndex%samplesPerRecord
+	return time.Duration(record)*h.DataRecordDuration + time.Duration(sample*int64(h.DataRecordDuration)/samplesPerRecord)
+}
+
+// sampleBytes returns the size of a sample in bytes, defaulting to the 2 bytes of EDF.
+func (h *Header) sampleBytes() int {
+	if h.BytesPerSample == 3 {
+		return 3
+	}
+	return 2
+}
+
+// recordSize returns the size in bytes of a single data record with samples of the
+// given width.
+func (h *Header) recordSize(sampleWidth int) int64 {
+	var size int64
+	for _, signal := range h.Signals {
+		size += int64(signal.SamplesPerRecord) * int64(sampleWidth)
+	}
+	return size
+}
+
+// recordsInFile returns the number of complete data records that fit in a file of
+// the given size, along with any leftover bytes that don't make up a whole record.
+func (h *Header) recordsInFile(size int64, sampleWidth int) (int64, int64) {
+	data := size - int64(h.HeaderBytes)
+	recordSize := h.recordSize(sampleWidth)
+	if data <= 0 || recordSize <= 0 {
+		return 0, 0
+	}
+	return data / recordSize, data % recordSize
+}
+
+// signalOffset returns the byte offset of a signal's samples within a data record with
+// samples of the given width.
+func (h *Header) signalOffset(signalIndex, sampleWidth int) int64 {
+	var offset int64
+	for _, signal := range h.Signals[:signalIndex] {
+		offset += int64(signal.SamplesPerRecord) * int64(sampleWidth)
+	}
+	return offset
+}
+
+// signalBlock returns the bytes holding a signal's samples within a raw data record
+// with samples of the given width.
+func (h *Header) signalBlock(record []byte, signalIndex, sampleWidth int) []byte {
+	offset := h.signalOffset(signalIndex, sampleWidth)
+	return record[offset : offset+int64(h.Signals[signalIndex].SamplesPerRecord)*int64(sampleWidth)]
+}
+
+// SampleRate returns the sample rate of a signal in samples per second, or 0 if the
+// signal index is out of range or the data record duration isn't positive.
+func (h *Header) SampleRate(signalIndex int) float64 {
+	if signalIndex < 0 || signalIndex >= len(h.Signals) {
+		return 0
+	}
+	return h.Signals[signalIndex].SampleRate(h.DataRecordDuration)
+}
@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Validate checks that an EDF file is usable. It parses the header, runs all Lint
+// checks, verifies the size of the data matches the header, and reads the first and
+// last data record. All problems found are combined into the returned error.
+//
+// Only the boundary records are read, so this is cheap even for very large files,
+// but corruption in the middle of the data will go unnoticed.
+func Validate(r io.ReadSeeker) error {
+	er, err := Open(r)
+	if err != nil {
+		return err
+	}
+
+	problems := er.Lint()
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("error seeking to end of file: %w", err)
+	}
+
+	if er.recordsCounted {
+		problems = append(problems, ErrUnknownRecordCount)
+	} else if records, _ := er.hdr.recordsInFile(size, er.sampleWidth); records != int64(er.hdr.DataRecords) {
+		// A leftover of less than a record is tolerated here, and reported by Lint.
+		expected := er.DataOffset() + int64(er.hdr.DataRecords)*er.hdr.recordSize(er.sampleWidth)
+		problems = append(problems, fmt.Errorf("file is %d bytes, expected %d from header", size, expected))
+	}
+
+	if er.hdr.DataRecords > 0 {
+		b := make([]byte, er.hdr.recordSize(er.sampleWidth))
+		for _, record := range []int{0, er.hdr.DataRecords - 1} {
+			if err := er.validateRecord(record, b); err != nil {
+				problems = append(problems, fmt.Errorf("record %d: %w", record, err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return multiError(problems)
+	}
+
+	return nil
+}
+
+// validateRecord reads a data record and checks its annotations can be parsed.
+func (er *Reader) validateRecord(record int, b []byte) error {
+	if err := er.readRecord(record, b); err != nil {
+		return err
+	}
+
+	timekeeping := true
+	for i, signal := range er.hdr.Signals {
+		if !signal.IsAnnotations() {
+			continue
+		}
+
+		if _, err := parseTALs(er.hdr.signalBlock(b, i, er.sampleWidth), timekeeping); err != nil {
+			return fmt.Errorf("error parsing annotations: %w", err)
+		}
+		timekeeping = false
+	}
+
+	return nil
+}
+
+// ValidateHeader checks that a header describes a well formed file before it is
+// written: the signal count matches the signals, each signal has a non-empty (though
+// possibly inverted) physical range, a digital range that fits the sample size, and at
+// least one sample per record, the data record duration is positive (or zero for an
+// EDF+ file holding only annotations), and every field fits its byte budget in the
+// header. All problems found are combined into the returned error.
+func ValidateHeader(h *Header) error {
+	var problems []error
+
+	if len(h.Signals) != h.SignalCount {
+		problems = append(problems, fmt.Errorf("signal count is %d, but there are %d signals", h.SignalCount, len(h.Signals)))
+	}
+
+	annotationsOnly := len(h.Signals) > 0
+	for _, signal := range h.Signals {
+		annotationsOnly = annotationsOnly && signal.IsAnnotations()
+	}
+	if h.DataRecordDuration < 0 || (h.DataRecordDuration == 0 && !annotationsOnly) {
+		problems = append(problems, fmt.Errorf("data record duration %s is not positive", h.DataRecordDuration))
+	}
+	if _, err := formatDuration(h.DataRecordDuration); err != nil {
+		problems = append(problems, err)
+	}
+
+	reserved, err := joinReserved(h.Reserved, h.VendorTag)
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	fixed := map[string]string{
+		"Version":     string(h.Version),
+		"PatientID":   h.PatientID,
+		"RecordingID": h.RecordingID,
+		"Reserved":    reserved,
+	}
+	for _, field := range fixedHeaderFields {
+		if value, ok := fixed[field.name]; ok && len(value) > field.width {
+			problems = append(problems, fmt.Errorf("%s is %d bytes, longer than %d", field.name, len(value), field.width))
+		}
+	}
+
+	sampleBytes := h.sampleBytes()
+	if h.Version == VersionBDF {
+		sampleBytes = 3
+	}
+
+	for i, signal := range h.Signals {
+		// A physical minimum above the maximum is allowed, giving an inverted polarity.
+		if signal.PhysicalMin == signal.PhysicalMax {
+			problems = append(problems, fmt.Errorf("signal %d: physical range [%g, %g] is empty", i, signal.PhysicalMin, signal.PhysicalMax))
+		}
+		if signal.DigitalMin >= signal.DigitalMax {
+			problems = append(problems, fmt.Errorf("signal %d: digital minimum %d is not less than maximum %d", i, signal.DigitalMin, signal.DigitalMax))
+		}
+		if !fitsSample(signal.DigitalMin, sampleBytes) || !fitsSample(signal.DigitalMax, sampleBytes) {
+			problems = append(problems, fmt.Errorf("signal %d: digital range [%d, %d] exceeds %d-bit sample range", i, signal.DigitalMin, signal.DigitalMax, sampleBytes*8))
+		}
+		if signal.SamplesPerRecord <= 0 {
+			problems = append(problems, fmt.Errorf("signal %d: samples per record %d is not positive", i, signal.SamplesPerRecord))
+		}
+
+		for _, value := range []float64{signal.PhysicalMin, signal.PhysicalMax} {
+			if _, err := formatPhysicalValue(value); err != nil {
+				problems = append(problems, fmt.Errorf("signal %d: %w", i, err))
+			}
+		}
+
+		fields := map[string]string{
+			"Label":             signal.Label,
+			"TransducerType":    signal.TransducerType,
+			"PhysicalDimension": signal.PhysicalDimension,
+			"Prefiltering":      signal.Prefiltering,
+			"Reserved":          signal.Reserved,
+		}
+		for _, field := range signalHeaderFields {
+			if value, ok := fields[field.name]; ok && len(value) > field.width {
+				problems = append(problems, fmt.Errorf("signal %d: %s is %d bytes, longer than %d", i, field.name, len(value), field.width))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return multiError(problems)
+	}
+
+	return nil
+}
+
+// ValidateAnnotations checks the EDF+ annotation signals of the file for corruption
+// that would trip up consumers of the annotations. Every data record's timekeeping
+// annotation must give the record's onset: for continuous files, the first record's
+// onset, which may be a fraction of a second after the start time, plus the record's
+// index times the data record duration, or for discontinuous (EDF+D) files at least the
+// end of the previous record. The onsets of all annotations must fall within the
+// recording, from its start up to the end of the last data record. The first problem
+// found is returned, identifying the offending record and onset. Files without
+// annotation signals are always valid.
+func (er *Reader) ValidateAnnotations() error {
+	annotationSignal := -1
+	for i, signal := range er.hdr.Signals {
+		if signal.IsAnnotations() {
+			annotationSignal = i
+			break
+		}
+	}
+	if annotationSignal < 0 {
+		return nil
+	}
+
+	records := er.dataRecords()
+	duration := er.hdr.DataRecordDuration
+
+	// Check the timekeeping annotations first, as they determine where the recording ends.
+	b := make([]byte, er.hdr.recordSize(er.sampleWidth))
+	var end, firstOnset time.Duration
+	for record := 0; record < records; record++ {
+		if err := er.readRecord(record, b); err != nil {
+			return err
+		}
+
+		onset, err := parseTimekeeping(er.hdr.signalBlock(b, annotationSignal, er.sampleWidth))
+		if err != nil {
+			return fmt.Errorf("record %d: %w", record, err)
+		}
+
+		if er.hdr.Type == EDFPlusDiscontinuous {
+			if onset < end {
+				return fmt.Errorf("record %d: timekeeping onset %s is before the end of the previous record at %s", record, onset, end)
+			}
+		} else if record == 0 {
+			if onset < 0 || onset >= time.Second {
+				return fmt.Errorf("record 0: timekeeping onset %s is not within a second of the start time", onset)
+			}
+			firstOnset = onset
+		} else if expected := firstOnset + time.Duration(record)*duration; onset != expected {
+			return fmt.Errorf("record %d: timekeeping onset %s, expected %s", record, onset, expected)
+		}
+		end = onset + duration
+	}
+
+	byRecord, err := er.AnnotationsByRecord()
+	if err != nil {
+		return err
+	}
+
+	for record, annotations := range byRecord {
+		for _, annotation := range annotations {
+			if annotation.Onset < 0 || annotation.Onset >= end {
+				return fmt.Errorf("record %d: annotation %q onset %s is outside the recording [0s, %s)",
+					record, strings.Join(annotation.Texts, ", "), annotation.Onset, end)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	b, err := os.ReadFile("testdata/resmed_BRP.edf")
+	require.NoError(t, err)
+
+	t.Run("Valid", func(t *testing.T) {
+		require.NoError(t, edf.Validate(bytes.NewReader(b)))
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		err := edf.Validate(bytes.NewReader(b[:len(b)-100]))
+		require.ErrorContains(t, err, "file is 361260 bytes, expected 361360 from header")
+		require.ErrorContains(t, err, "record 39: error reading data record")
+	})
+
+	t.Run("TrailingBytes", func(t *testing.T) {
+		padded := append(append([]byte{}, b...), "\r\n"...)
+
+		// The junk isn't mistaken for a size mismatch, only reported as a lint problem.
+		err := edf.Validate(bytes.NewReader(padded))
+		require.EqualError(t, err, "2 trailing bytes after the last complete data record")
+
+		er, err := edf.Open(bytes.NewReader(padded))
+		require.NoError(t, err)
+
+		sr, err := er.Signal(0)
+		require.NoError(t, err)
+
+		samples := make([]float64, 40*1500)
+		n, err := sr.Read(samples)
+		require.NoError(t, err)
+		require.Equal(t, len(samples), n)
+	})
+}
+
+// sparseFile is an io.ReadSeeker over a virtual file of the given size that consists
+// of a header followed by zeros, without actually allocating the data.
+type sparseFile struct {
+	header []byte
+	size   int64
+	pos    int64
+}
+
+func (f *sparseFile) Read(p []byte) (int, error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+	if remaining := f.size - f.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	for i := range p {
+		p[i] = 0
+	}
+	if f.pos < int64(len(f.header)) {
+		copy(p, f.header[f.pos:])
+	}
+	f.pos += int64(len(p))
+	return len(p), nil
+}
+
+func (f *sparseFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.pos = offset
+	case io.SeekCurrent:
+		f.pos += offset
+	case io.SeekEnd:
+		f.pos = f.size + offset
+	}
+	return f.pos, nil
+}
+
+func TestValidateMultiGigabyte(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -1,
+				PhysicalMax:      1,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 30000,
+			},
+		},
+	}
+
+	// 100000 records of 60000 bytes each, ~6 GB in total.
+	f := writeTestFile(t, hdr, nil)
+	patchTestFile(t, f, 236, "100000  ")
+
+	header, err := io.ReadAll(f)
+	require.NoError(t, err)
+
+	size := int64(len(header)) + 100000*60000
+	require.Greater(t, size, int64(math.MaxUint32))
+
+	require.NoError(t, edf.Validate(&sparseFile{header: header, size: size}))
+}
+
+func TestValidateHeader(t *testing.T) {
+	valid := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  256,
+			},
+		},
+	}
+
+	require.NoError(t, edf.ValidateHeader(&valid))
+
+	// A negative amplifier gain inverts the physical range.
+	inverted := valid
+	inverted.Signals = []edf.SignalHeader{valid.Signals[0]}
+	inverted.Signals[0].PhysicalMin, inverted.Signals[0].PhysicalMax = 500, -500
+	require.NoError(t, edf.ValidateHeader(&inverted))
+
+	hdr := valid
+	hdr.SignalCount = 2
+	hdr.DataRecordDuration = 0
+	hdr.PatientID = strings.Repeat("X", 81)
+	hdr.Signals = []edf.SignalHeader{valid.Signals[0]}
+	hdr.Signals[0].Label = "A label longer than sixteen bytes"
+	hdr.Signals[0].PhysicalMin = 500
+	hdr.Signals[0].DigitalMax = 40000
+	hdr.Signals[0].SamplesPerRecord = 0
+
+	err := edf.ValidateHeader(&hdr)
+	require.ErrorContains(t, err, "signal count is 2, but there are 1 signals")
+	require.ErrorContains(t, err, "data record duration 0s is not positive")
+	require.ErrorContains(t, err, "PatientID is 81 bytes, longer than 80")
+	require.ErrorContains(t, err, "signal 0: Label is 33 bytes, longer than 16")
+	require.ErrorContains(t, err, "signal 0: physical range [500, 500] is empty")
+	require.ErrorContains(t, err, "signal 0: digital range [-2048, 40000] exceeds 16-bit sample range")
+	require.ErrorContains(t, err, "signal 0: samples per record 0 is not positive")
+
+	// BDF files allow 24-bit digital ranges.
+	hdr = valid
+	hdr.Version = edf.VersionBDF
+	hdr.Signals = []edf.SignalHeader{valid.Signals[0]}
+	hdr.Signals[0].DigitalMax = 8388607
+	require.NoError(t, edf.ValidateHeader(&hdr))
+
+	// Create fails fast on an invalid header.
+	hdr.Version = edf.Version0
+	_, err = edf.Create(createTestFile(t), hdr)
+	require.ErrorContains(t, err, "exceeds 16-bit sample range")
+}
+
+func TestReaderValidateAnnotations(t *testing.T) {
+	tests := []struct {
+		name    string
+		records [][]byte
+		wantErr string
+	}{
+		{
+			name: "Valid",
+			records: [][]byte{
+				annotatedRecord("+0\x14\x14\x00", "+0.5\x14Lights off\x14\x00"),
+				annotatedRecord("+1\x14\x14\x00", "+1.75\x150.25\x14Arousal\x14\x00"),
+			},
+		},
+		{
+			name: "OnsetOutOfBounds",
+			records: [][]byte{
+				annotatedRecord("+0\x14\x14\x00"),
+				annotatedRecord("+1\x14\x14\x00", "+5\x14Lights on\x14\x00"),
+			},
+			wantErr: `record 1: annotation "Lights on" onset 5s is outside the recording [0s, 2s)`,
+		},
+		{
+			name: "NegativeOnset",
+			records: [][]byte{
+				annotatedRecord("+0\x14\x14\x00", "-1\x14Lights off\x14\x00"),
+			},
+			wantErr: "record 0: annotation \"Lights off\" onset -1s",
+		},
+		{
+			name: "Timekeeping",
+			records: [][]byte{
+				annotatedRecord("+0\x14\x14\x00"),
+				annotatedRecord("+3\x14\x14\x00"),
+			},
+			wantErr: "record 1: timekeeping onset 3s, expected 1s",
+		},
+		{
+			name: "SubSecondStart",
+			records: [][]byte{
+				annotatedRecord("+0.5\x14\x14\x00", "+0.75\x14Lights off\x14\x00"),
+				annotatedRecord("+1.5\x14\x14\x00"),
+			},
+		},
+		{
+			name: "SubSecondStartTimekeeping",
+			records: [][]byte{
+				annotatedRecord("+0.5\x14\x14\x00"),
+				annotatedRecord("+1\x14\x14\x00"),
+			},
+			wantErr: "record 1: timekeeping onset 1s, expected 1.5s",
+		},
+		{
+			name: "LateStart",
+			records: [][]byte{
+				annotatedRecord("+1.5\x14\x14\x00"),
+			},
+			wantErr: "record 0: timekeeping onset 1.5s is not within a second of the start time",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			er, err := edf.Open(writeRawTestFile(t, annotatedHeader, tt.records))
+			require.NoError(t, err)
+
+			err = er.ValidateAnnotations()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ExportWAV writes a signal to w as a mono 16-bit PCM WAV file, so it can be played
+// back with ordinary audio tools. The signal's digital samples are written directly,
+// without calibration, with its declared digital range [DigitalMin, DigitalMax]
+// linearly mapped onto the full 16-bit range [-32768, 32767]. For an EDF signal that
+// already spans the full 16-bit range the samples are written unchanged, and a BDF
+// signal is scaled down to 16 bits. Samples outside the digital range are clipped.
+//
+// WAV sample rates are whole numbers of Hz, so the signal's sample rate,
+// SamplesPerRecord / DataRecordDuration, is rounded to the nearest integer with halves
+// rounded up, and to at least 1 Hz. A signal sampled at 0.5 Hz or less therefore plays
+// back faster than real time.
+func (er *Reader) ExportWAV(w io.Writer, signalIndex int) error {
+	sr, err := er.Signal(signalIndex)
+	if err != nil {
+		return err
+	}
+
+	signal := er.hdr.Signals[signalIndex]
+	if signal.IsAnnotations() {
+		return fmt.Errorf("signal %d is an annotations signal", signalIndex)
+	}
+	if signal.DigitalMax <= signal.DigitalMin {
+		return fmt.Errorf("signal %d has an invalid digital range [%d, %d]", signalIndex, signal.DigitalMin, signal.DigitalMax)
+	}
+	if sr.dataRecords < 0 {
+		return fmt.Errorf("number of data records is unknown")
+	}
+
+	rate := er.hdr.SampleRate(signalIndex)
+	if rate <= 0 {
+		return fmt.Errorf("signal %d has no sample rate", signalIndex)
+	}
+	wavRate := math.Round(rate)
+	if wavRate < 1 {
+		wavRate = 1
+	}
+
+	dataBytes := sr.NumSamples() * 2
+	if 36+dataBytes > math.MaxUint32 {
+		return fmt.Errorf("signal %d is too long for a WAV file", signalIndex)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	header := struct {
+		RIFF          [4]byte
+		Size          uint32
+		WAVE          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		Format        uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		Size:          uint32(36 + dataBytes),
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		Format:        1, // PCM
+		Channels:      1,
+		SampleRate:    uint32(wavRate),
+		ByteRate:      uint32(wavRate) * 2,
+		BlockAlign:    2,
+		BitsPerSample: 16,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      uint32(dataBytes),
+	}
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("error writing WAV header: %w", err)
+	}
+
+	digital := make([]int32, 4096)
+	pcm := make([]byte, 2*len(digital))
+	for {
+		n, err := sr.ReadDigital(digital)
+		for i, d := range digital[:n] {
+			binary.LittleEndian.PutUint16(pcm[2*i:], uint16(digitalToPCM16(d, signal.DigitalMin, signal.DigitalMax)))
+		}
+		if _, werr := bw.Write(pcm[:2*n]); werr != nil {
+			return fmt.Errorf("error writing WAV samples: %w", werr)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// digitalToPCM16 linearly maps a digital value in [dmin, dmax] onto the 16-bit range,
+// clipping values outside the digital range.
+func digitalToPCM16(d int32, dmin, dmax int) int16 {
+	v := int64(d)
+	if v < int64(dmin) {
+		v = int64(dmin)
+	} else if v > int64(dmax) {
+		v = int64(dmax)
+	}
+
+	const span = math.MaxInt16 - math.MinInt16
+	scaled := math.Round(float64(v-int64(dmin)) * span / float64(int64(dmax)-int64(dmin)))
+	return int16(int64(scaled) + math.MinInt16)
+}
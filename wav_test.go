@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportWAV(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: 2 * time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Snore",
+				PhysicalMin:      -2048,
+				PhysicalMax:      2047,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 3, // 1.5 Hz, rounded up to 2 Hz.
+			},
+		},
+	}
+
+	er, err := edf.Open(writeTestFile(t, hdr, [][][]float64{
+		{{-2048, 0, 2047}},
+		{{-1024, 1024, 1}},
+	}))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, er.ExportWAV(&buf, 0))
+
+	wav := buf.Bytes()
+	require.Len(t, wav, 44+6*2)
+	require.Equal(t, "RIFF", string(wav[0:4]))
+	require.Equal(t, uint32(len(wav)-8), binary.LittleEndian.Uint32(wav[4:]))
+	require.Equal(t, "WAVEfmt ", string(wav[8:16]))
+	require.Equal(t, uint16(1), binary.LittleEndian.Uint16(wav[20:]))  // PCM
+	require.Equal(t, uint16(1), binary.LittleEndian.Uint16(wav[22:]))  // Mono
+	require.Equal(t, uint32(2), binary.LittleEndian.Uint32(wav[24:]))  // Sample rate
+	require.Equal(t, uint32(4), binary.LittleEndian.Uint32(wav[28:]))  // Byte rate
+	require.Equal(t, uint16(16), binary.LittleEndian.Uint16(wav[34:])) // Bits per sample
+	require.Equal(t, "data", string(wav[36:40]))
+	require.Equal(t, uint32(12), binary.LittleEndian.Uint32(wav[40:]))
+
+	samples := make([]int16, 6)
+	require.NoError(t, binary.Read(bytes.NewReader(wav[44:]), binary.LittleEndian, samples))
+
+	// The 12-bit digital range is stretched over the full 16-bit range.
+	require.Equal(t, []int16{-32768, 8, 32767, -16380, 16395, 24}, samples)
+
+	t.Run("FullRange", func(t *testing.T) {
+		hdr := hdr
+		hdr.Signals = []edf.SignalHeader{hdr.Signals[0]}
+		hdr.Signals[0].PhysicalMin, hdr.Signals[0].PhysicalMax = -32768, 32767
+		hdr.Signals[0].DigitalMin, hdr.Signals[0].DigitalMax = -32768, 32767
+
+		er, err := edf.Open(writeTestFile(t, hdr, [][][]float64{{{-32768, -1, 32767}}}))
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, er.ExportWAV(&buf, 0))
+
+		samples := make([]int16, 3)
+		require.NoError(t, binary.Read(bytes.NewReader(buf.Bytes()[44:]), binary.LittleEndian, samples))
+		require.Equal(t, []int16{-32768, -1, 32767}, samples)
+	})
+
+	t.Run("OutOfRange", func(t *testing.T) {
+		require.Error(t, er.ExportWAV(&bytes.Buffer{}, 1))
+	})
+}
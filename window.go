@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WindowReader yields fixed size, possibly overlapping, windows of a signal's physical
+// values.
+type WindowReader struct {
+	sr      *SignalReader
+	size    int       // Number of samples in a window
+	step    int       // Number of samples between the starts of consecutive windows
+	buf     []float64 // Current window, reused between calls to Next
+	filled  int       // Number of valid samples in buf
+	started bool      // Whether the first window has been read
+	eof     bool      // Whether the signal has been exhausted
+}
+
+// Windows returns a WindowReader yielding windows of size samples, the start of each
+// advancing by step samples from the last, as used for feature extraction and
+// spectrograms. Windows overlap when step is less than size, and samples are skipped
+// when it is greater. Reading continues from the SignalReader's current position.
+func (sr *SignalReader) Windows(size, step int) (*WindowReader, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("invalid window size %d", size)
+	}
+	if step < 1 {
+		return nil, fmt.Errorf("invalid window step %d", step)
+	}
+
+	return &WindowReader{
+		sr:   sr,
+		size: size,
+		step: step,
+		buf:  make([]float64, size),
+	}, nil
+}
+
+// Next returns the next window. The returned slice is reused by the following call to
+// Next, so it must be copied if it is needed for longer. If the signal ends part way
+// through a window, the final window is shorter than the window size; after that Next
+// returns io.EOF.
+func (wr *WindowReader) Next() ([]float64, error) {
+	if wr.started {
+		if wr.eof {
+			return nil, io.EOF
+		}
+
+		// Advance the window, keeping any overlap with the last one.
+		if wr.step < wr.filled {
+			copy(wr.buf, wr.buf[wr.step:wr.filled])
+			wr.filled -= wr.step
+		} else {
+			if err := wr.skip(wr.step - wr.filled); err != nil {
+				return nil, err
+			}
+			wr.filled = 0
+		}
+	}
+	wr.started = true
+
+	kept := wr.filled
+	for wr.filled < wr.size && !wr.eof {
+		n, err := wr.sr.Read(wr.buf[wr.filled:])
+		wr.filled += n
+		if errors.Is(err, io.EOF) {
+			wr.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	// A window without new samples would lie entirely within the last one.
+	if wr.filled == kept {
+		wr.eof = true
+		return nil, io.EOF
+	}
+
+	return wr.buf[:wr.filled], nil
+}
+
+// skip discards n samples between non-overlapping windows.
+func (wr *WindowReader) skip(n int) error {
+	for n > 0 && !wr.eof {
+		chunk := wr.buf
+		if n < len(chunk) {
+			chunk = chunk[:n]
+		}
+
+		read, err := wr.sr.Read(chunk)
+		n -= read
+		if errors.Is(err, io.EOF) {
+			wr.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
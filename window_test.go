@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: MPL-2.0
+/*
+ * Copyright (C) 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package edf_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/OpenPSG/edf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalReaderWindows(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -2048,
+				PhysicalMax:      2047,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 10,
+			},
+		},
+	}
+
+	// 30 samples counting up from 0, across 3 records.
+	records := make([][][]float64, 3)
+	for i := range records {
+		samples := make([]float64, 10)
+		for j := range samples {
+			samples[j] = float64(i*10 + j)
+		}
+		records[i] = [][]float64{samples}
+	}
+
+	er, err := edf.Open(writeTestFile(t, hdr, records))
+	require.NoError(t, err)
+
+	// span returns the samples from start up to, but not including, end.
+	span := func(start, end int) []float64 {
+		var samples []float64
+		for i := start; i < end; i++ {
+			samples = append(samples, float64(i))
+		}
+		return samples
+	}
+
+	tests := []struct {
+		name string
+		size int
+		step int
+		want [][]float64
+	}{
+		{
+			name: "Overlapping",
+			size: 8,
+			step: 5,
+			want: [][]float64{span(0, 8), span(5, 13), span(10, 18), span(15, 23), span(20, 28), span(25, 30)},
+		},
+		{
+			name: "Contiguous",
+			size: 10,
+			step: 10,
+			want: [][]float64{span(0, 10), span(10, 20), span(20, 30)},
+		},
+		{
+			name: "Skipping",
+			size: 4,
+			step: 12,
+			want: [][]float64{span(0, 4), span(12, 16), span(24, 28)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr, err := er.Signal(0)
+			require.NoError(t, err)
+
+			wr, err := sr.Windows(tt.size, tt.step)
+			require.NoError(t, err)
+
+			var windows [][]float64
+			for {
+				window, err := wr.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+
+				// The window buffer is reused, so keep a copy.
+				windows = append(windows, append([]float64(nil), window...))
+			}
+
+			require.Equal(t, tt.want, windows)
+
+			_, err = wr.Next()
+			require.Equal(t, io.EOF, err)
+		})
+	}
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	_, err = sr.Windows(0, 1)
+	require.Error(t, err)
+
+	_, err = sr.Windows(1, 0)
+	require.Error(t, err)
+}
@@ -11,24 +11,113 @@ package edf
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Writer writes EDF files.
 type Writer struct {
 	w           io.WriteSeeker
+	bw          *bufio.Writer // Buffers data records on their way to w.
 	hdr         *Header
-	dataRecords int // Number of data records written so far.
+	dataRecords int         // Number of data records written so far.
+	pending     [][]float64 // Samples buffered per signal until a complete record is available.
+	record      []byte      // Scratch space for encoding a data record, reused across records.
+
+	unknownPlaceholders bool                // Fill empty identification fields with EDF+ "X" placeholders.
+	verifyHeaderLayout  bool                // Check header fields are written at their mandated offsets.
+	canonicalDimensions bool                // Rewrite physical dimensions to their canonical spelling.
+	maxRecordSize       int                 // Maximum size of a data record in bytes.
+	warnOversizeRecords bool                // Collect a warning for oversized records rather than failing.
+	calibrations        map[int]Calibration // Calibrations overriding the physical to digital conversion.
+	fillNonFinite       bool                // Replace NaN and infinite samples rather than failing.
+	nonFiniteFill       float64             // Physical value replacing NaN and infinite samples.
+	padWithValue        bool                // Pad the final record with padValue rather than the physical minimum.
+	padValue            float64             // Physical value padding out the final record.
+	strictRange         bool                // Reject samples outside the physical range rather than clamping them.
+	appending           bool                // Appending to an existing file, whose header is left as is apart from the record count.
+
+	annotations []Annotation // Annotations queued for the EDF Annotations signal.
+
+	warnings []error // Warnings collected while writing.
+}
+
+// DefaultMaxRecordSize is the maximum size of a data record in bytes, as recommended
+// by the EDF standard.
+const DefaultMaxRecordSize = 61440
+
+// RecordSizeWarning reports a data record larger than the writer's maximum record size.
+// It is returned by WriteRecord, or collected by Warnings if the writer was created with
+// WithOversizeRecordWarnings.
+type RecordSizeWarning struct {
+	Record int // Index of the data record
+	Size   int // Size of the data record in bytes
+	Limit  int // Maximum size of a data record in bytes
+}
+
+func (w *RecordSizeWarning) Error() string {
+	return fmt.Sprintf("data record %d too large: %d bytes, max is %d bytes", w.Record, w.Size, w.Limit)
 }
 
 // Create creates a new EDF writer that writes to the given writer.
-func Create(w io.WriteSeeker, hdr Header) (*Writer, error) {
+func Create(w io.WriteSeeker, hdr Header, opts ...WriterOption) (*Writer, error) {
 	hdr.DataRecords = -1 // Unknown number of data records (at this time).
 
-	ew := &Writer{w: w, hdr: &hdr}
+	// BDF files store 24-bit samples, EDF files 16-bit ones.
+	switch {
+	case hdr.Version == VersionBDF:
+		hdr.BytesPerSample = 3
+	case hdr.BytesPerSample == 0 || hdr.BytesPerSample == 2:
+		hdr.BytesPerSample = 2
+	default:
+		return nil, fmt.Errorf("%d byte samples require the BDF version", hdr.BytesPerSample)
+	}
+
+	// An EDF+ type takes precedence over any continuity marker already in the reserved
+	// field.
+	if hdr.Type != Plain {
+		hdr.Reserved = continuityMarker(hdr.Type, hdr.Version)
+	}
+
+	ew := &Writer{
+		w:             w,
+		bw:            bufio.NewWriter(w),
+		hdr:           &hdr,
+		pending:       make([][]float64, hdr.SignalCount),
+		maxRecordSize: DefaultMaxRecordSize,
+	}
+
+	for _, opt := range opts {
+		opt(ew)
+	}
+
+	if ew.unknownPlaceholders {
+		if hdr.PatientID == "" {
+			hdr.PatientID = "X X X X"
+		}
+		if hdr.RecordingID == "" {
+			hdr.RecordingID = fmt.Sprintf("Startdate %s X X X", strings.ToUpper(hdr.StartTime.Format("02-Jan-2006")))
+		}
+	}
+
+	if ew.canonicalDimensions {
+		// Copy the signals so the caller's header is left untouched.
+		hdr.Signals = append([]SignalHeader(nil), hdr.Signals...)
+		for i := range hdr.Signals {
+			hdr.Signals[i].PhysicalDimension = canonicalDimension(hdr.Signals[i].PhysicalDimension)
+		}
+	}
+
+	if err := ValidateHeader(&hdr); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
 
 	// Write the initial header
 	if err := ew.writeHeader(); err != nil {
@@ -38,48 +127,364 @@ func Create(w io.WriteSeeker, hdr Header) (*Writer, error) {
 	return ew, nil
 }
 
+// OpenWriter opens an existing EDF file for appending data records, e.g. to keep
+// extending a file during live capture. The header is parsed from rw and the number of
+// data records already written is taken from the size of the file, so a file whose
+// header was never finalized can still be appended to. Any incomplete data record at
+// the end of the file, as left by an interrupted write, is overwritten by the first
+// appended record; if none is appended it is left in place after the counted records,
+// where readers ignore it (and Lint reports it as trailing bytes), as an
+// io.ReadWriteSeeker can't be truncated. Records written with WriteRecord must match the
+// signal layout of the file's header, and Close updates the header's record count,
+// leaving the rest of the header untouched. The header must be of the standard size for
+// its signal count.
+func OpenWriter(rw io.ReadWriteSeeker, opts ...WriterOption) (*Writer, error) {
+	if _, err := rw.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error seeking to header: %w", err)
+	}
+
+	er, err := Open(rw)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := er.Header()
+	if expected := 256 * (hdr.SignalCount + 1); hdr.HeaderBytes != expected {
+		return nil, fmt.Errorf("header is %d bytes, expected %d for %d signals", hdr.HeaderBytes, expected, hdr.SignalCount)
+	}
+
+	size, err := rw.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("error seeking to end of file: %w", err)
+	}
+
+	records, _ := hdr.recordsInFile(size, er.sampleWidth)
+	if _, err := rw.Seek(int64(hdr.HeaderBytes)+records*hdr.recordSize(er.sampleWidth), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error seeking to end of data: %w", err)
+	}
+
+	ew := &Writer{
+		w:             rw,
+		bw:            bufio.NewWriter(rw),
+		hdr:           &hdr,
+		dataRecords:   int(records),
+		pending:       make([][]float64, hdr.SignalCount),
+		maxRecordSize: DefaultMaxRecordSize,
+		appending:     true,
+	}
+
+	for _, opt := range opts {
+		opt(ew)
+	}
+
+	return ew, nil
+}
+
+// WriteSignals writes a complete EDF file containing the given signals, all sampled at
+// sampleRate (which must be a whole number of samples per second) and of equal length.
+// Signals are written in label order using one second data records, or the largest
+// fraction of a second that keeps each record within DefaultMaxRecordSize, with a final
+// partial record padded with each signal's physical minimum. The physical range of each signal is
+// taken from its observed minimum and maximum and mapped onto the full 16-bit digital range.
+func WriteSignals(w io.WriteSeeker, signals map[string][]float64, sampleRate float64, start time.Time) error {
+	if len(signals) == 0 {
+		return fmt.Errorf("no signals to write")
+	}
+
+	if sampleRate <= 0 || sampleRate != math.Trunc(sampleRate) {
+		return fmt.Errorf("sample rate must be a whole number of samples per second, got %g", sampleRate)
+	}
+
+	labels := make([]string, 0, len(signals))
+	for label := range signals {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	samplesPerRecord, duration, err := recordLayout(int(sampleRate), len(labels))
+	if err != nil {
+		return err
+	}
+
+	hdr := Header{
+		Version:            Version0,
+		StartTime:          start,
+		DataRecordDuration: duration,
+		SignalCount:        len(labels),
+		Signals:            make([]SignalHeader, len(labels)),
+	}
+
+	length := len(signals[labels[0]])
+	for i, label := range labels {
+		samples := signals[label]
+		if len(samples) != length {
+			return fmt.Errorf("signal %q has %d samples, expected %d", label, len(samples), length)
+		}
+
+		pmin, pmax := observedRange(samples)
+		hdr.Signals[i] = SignalHeader{
+			Label:            label,
+			PhysicalMin:      pmin,
+			PhysicalMax:      pmax,
+			DigitalMin:       math.MinInt16,
+			DigitalMax:       math.MaxInt16,
+			SamplesPerRecord: samplesPerRecord,
+		}
+	}
+
+	ew, err := Create(w, hdr)
+	if err != nil {
+		return err
+	}
+
+	for i, label := range labels {
+		ew.pending[i] = append(ew.pending[i], signals[label]...)
+	}
+
+	if err := ew.writePending(); err != nil {
+		return err
+	}
+
+	return ew.Close()
+}
+
+// recordLayout returns the samples per record and duration of the longest data record,
+// no longer than a second, that holds a whole number of samples from each of signals
+// signals sampled at rate and still fits within DefaultMaxRecordSize. Only durations
+// that are exactly representable in the header are considered.
+func recordLayout(rate, signals int) (int, time.Duration, error) {
+	for k := 1; k <= rate; k++ {
+		if rate%k != 0 || int64(time.Second)%int64(k) != 0 {
+			continue
+		}
+
+		duration := time.Second / time.Duration(k)
+		if len(strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)) > 8 {
+			continue
+		}
+
+		if samplesPerRecord := rate / k; signals*samplesPerRecord*2 <= DefaultMaxRecordSize {
+			return samplesPerRecord, duration, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("no data record duration fits %d signals at %d Hz within %d bytes", signals, rate, DefaultMaxRecordSize)
+}
+
+// WritePadded writes signals of differing lengths, as happens when a sensor drops out
+// before the end of an acquisition, to a new EDF file. Every signal is padded out to the
+// record boundary of the longest signal, with its physical minimum or the value given
+// by WithPadValue, so the file holds the same duration of every signal. It returns the
+// number of padding samples appended to each signal, which is 0 for EDF Annotations
+// signals as they aren't padded.
+func WritePadded(w io.WriteSeeker, hdr Header, signals [][]float64, opts ...WriterOption) ([]int, error) {
+	if len(signals) != hdr.SignalCount {
+		return nil, fmt.Errorf("got %d signals, header declares %d", len(signals), hdr.SignalCount)
+	}
+
+	ew, err := Create(w, hdr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var records int
+	for i, samples := range signals {
+		samplesPerRecord := ew.hdr.Signals[i].SamplesPerRecord
+		if samplesPerRecord == 0 || ew.hdr.Signals[i].IsAnnotations() {
+			continue
+		}
+		n := (len(samples) + samplesPerRecord - 1) / samplesPerRecord
+		if n > records {
+			records = n
+		}
+	}
+
+	padding := make([]int, len(signals))
+	for i, samples := range signals {
+		if !ew.hdr.Signals[i].IsAnnotations() {
+			padding[i] = records*ew.hdr.Signals[i].SamplesPerRecord - len(samples)
+		}
+		if err := ew.WriteContinuous(i, samples); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ew.Close(); err != nil {
+		return nil, err
+	}
+
+	return padding, nil
+}
+
+// observedRange returns a physical range covering the given samples, widened so it
+// survives formatting into the header's 8 character physical min/max fields.
+func observedRange(samples []float64) (float64, float64) {
+	if len(samples) == 0 {
+		return -1, 1
+	}
+
+	lo, hi := samples[0], samples[0]
+	for _, sample := range samples[1:] {
+		lo = math.Min(lo, sample)
+		hi = math.Max(hi, sample)
+	}
+
+	pmin, pmax := math.Floor(lo*100)/100, math.Ceil(hi*100)/100
+	if len(fmt.Sprintf("%.2f", pmin)) > 8 {
+		pmin = math.Floor(lo)
+	}
+	if len(fmt.Sprintf("%.2f", pmax)) > 8 {
+		pmax = math.Ceil(hi)
+	}
+
+	if pmin == pmax {
+		pmax = pmin + 1
+	}
+
+	return pmin, pmax
+}
+
 // Close finalizes the EDF file by updating the header with the total number of data records.
 func (ew *Writer) Close() error {
+	// Write out any buffered samples that didn't make up a complete record.
+	if err := ew.flushPending(); err != nil {
+		return fmt.Errorf("error writing buffered samples: %w", err)
+	}
+
+	// Write out buffered records before seeking back to the header.
+	if err := ew.Flush(); err != nil {
+		return err
+	}
+
 	// Finalize the header with the actual number of data records
 	ew.hdr.DataRecords = ew.dataRecords
-	if err := ew.writeHeader(); err != nil {
+	if ew.appending {
+		if err := ew.writeDataRecords(); err != nil {
+			return fmt.Errorf("error writing header: %w", err)
+		}
+	} else if err := ew.writeHeader(); err != nil {
 		return fmt.Errorf("error writing header: %w", err)
 	}
 
+	if len(ew.annotations) > 0 {
+		return fmt.Errorf("%d annotations were not written, as there were no data records left to hold them", len(ew.annotations))
+	}
+
 	return nil
 }
 
-// WriteRecord writes a single data record to the EDF file.
+// WriteRecord writes a single data record to the EDF file. Each signal must hold
+// exactly its SamplesPerRecord samples, as anything else would misalign every record
+// that follows. The samples given for EDF Annotations signals are ignored (and may be
+// nil); their blocks are filled with the record's timekeeping annotation and any
+// annotations queued by WriteAnnotations.
 func (ew *Writer) WriteRecord(signals [][]float64) error {
 	if len(signals) != ew.hdr.SignalCount {
 		return fmt.Errorf("expected %d signals, got %d", ew.hdr.SignalCount, len(signals))
 	}
 
 	var totalSamples int
-	for _, signal := range signals {
+	for i, signal := range signals {
+		if ew.hdr.Signals[i].IsAnnotations() {
+			totalSamples += ew.hdr.Signals[i].SamplesPerRecord
+			continue
+		}
+		if len(signal) != ew.hdr.Signals[i].SamplesPerRecord {
+			return fmt.Errorf("signal %d: expected %d samples, got %d", i, ew.hdr.Signals[i].SamplesPerRecord, len(signal))
+		}
 		totalSamples += len(signal)
 	}
 
-	// As recommended by the EDF standard.
-	if totalSamples*2 > 61440 {
-		return fmt.Errorf("data record too large: %d bytes, max is 61440 bytes", totalSamples*2)
+	sampleBytes := ew.hdr.sampleBytes()
+	if size := totalSamples * sampleBytes; size > ew.maxRecordSize {
+		warning := &RecordSizeWarning{Record: ew.dataRecords, Size: size, Limit: ew.maxRecordSize}
+		if !ew.warnOversizeRecords {
+			return warning
+		}
+		ew.warnings = append(ew.warnings, warning)
 	}
 
-	writer := bufio.NewWriter(ew.w)
-
-	// Write each signal's data
+	// Encode each signal's data
+	if size := totalSamples * sampleBytes; cap(ew.record) < size {
+		ew.record = make([]byte, size)
+	}
+	b := ew.record[:totalSamples*sampleBytes]
+	var offset int
+	timekeeping := true
+	// Queued annotations are only dequeued once the record is written, so they aren't
+	// lost if encoding or writing it fails.
+	queued := ew.annotations
 	for i := 0; i < ew.hdr.SignalCount; i++ {
-		signal := ew.hdr.Signals[i]
-		for _, sample := range signals[i] {
-			digitalValue := convertPhysicalToDigital(sample, signal.PhysicalMin, signal.PhysicalMax, signal.DigitalMin, signal.DigitalMax)
-			if err := binary.Write(writer, binary.LittleEndian, int16(digitalValue)); err != nil {
-				return err
+		if ew.hdr.Signals[i].IsAnnotations() {
+			block := b[offset : offset+ew.hdr.Signals[i].SamplesPerRecord*sampleBytes]
+			written, err := ew.fillAnnotations(block, timekeeping, queued)
+			if err != nil {
+				return fmt.Errorf("signal %d: %w", i, err)
 			}
+			queued = queued[written:]
+			timekeeping = false
+			offset += len(block)
+			continue
 		}
+
+		samples, err := ew.replaceNonFinite(signals[i])
+		if err != nil {
+			return fmt.Errorf("signal %d: %w", i, err)
+		}
+
+		if calibration, ok := ew.calibrations[i]; ok {
+			if err := calibration.encodeSamples(b[offset:], ew.hdr.Signals[i], samples, sampleBytes); err != nil {
+				return fmt.Errorf("signal %d: %w", i, err)
+			}
+		} else {
+			if ew.strictRange {
+				if err := checkDigitalRange(ew.hdr.Signals[i], samples); err != nil {
+					return fmt.Errorf("signal %d: %w", i, err)
+				}
+			}
+			encodeSamples(b[offset:], ew.hdr.Signals[i], samples, sampleBytes)
+		}
+		offset += len(samples) * sampleBytes
 	}
 
-	// Ensure all data is flushed to the underlying writer
-	if err := writer.Flush(); err != nil {
+	if err := ew.writeRawRecord(b); err != nil {
+		return err
+	}
+
+	ew.annotations = queued
+	return nil
+}
+
+// replaceNonFinite checks samples for NaN and infinite values, which have no digital
+// representation. They are an error unless the writer was created with
+// WithNonFiniteFill, in which case a copy of samples is returned with them replaced.
+func (ew *Writer) replaceNonFinite(samples []float64) ([]float64, error) {
+	var replaced []float64
+	for i, sample := range samples {
+		if !math.IsNaN(sample) && !math.IsInf(sample, 0) {
+			continue
+		}
+
+		if !ew.fillNonFinite {
+			return nil, fmt.Errorf("sample %d is %v", i, sample)
+		}
+
+		if replaced == nil {
+			replaced = append([]float64(nil), samples...)
+		}
+		replaced[i] = ew.nonFiniteFill
+	}
+
+	if replaced != nil {
+		return replaced, nil
+	}
+	return samples, nil
+}
+
+// writeRawRecord writes an already encoded data record to the EDF file. The record is
+// buffered, so b may be reused as soon as it returns.
+func (ew *Writer) writeRawRecord(b []byte) error {
+	if _, err := ew.bw.Write(b); err != nil {
 		return err
 	}
 
@@ -87,6 +492,193 @@ func (ew *Writer) WriteRecord(signals [][]float64) error {
 	return nil
 }
 
+// Flush writes any buffered data records to the underlying writer. Records are
+// buffered for efficiency, so Flush must be called before the file is read while it's
+// still being written, e.g. during live capture. Close flushes automatically.
+func (ew *Writer) Flush() error {
+	if err := ew.bw.Flush(); err != nil {
+		return fmt.Errorf("error writing data records: %w", err)
+	}
+	return nil
+}
+
+// encodeSamples encodes physical sample values into b as little-endian digital values
+// of sampleBytes bytes each, using the signal's calibration. Values outside the
+// signal's range are clamped to its digital minimum or maximum.
+func encodeSamples(b []byte, signal SignalHeader, samples []float64, sampleBytes int) {
+	for i, sample := range samples {
+		digitalValue := convertPhysicalToDigital(sample, signal.PhysicalMin, signal.PhysicalMax, signal.DigitalMin, signal.DigitalMax)
+		encodeSample(b[i*sampleBytes:], digitalValue, sampleBytes)
+	}
+}
+
+// encodeSample encodes a single little-endian digital value of sampleBytes bytes.
+func encodeSample(b []byte, digital int32, sampleBytes int) {
+	if sampleBytes == 3 {
+		b[0], b[1], b[2] = byte(digital), byte(digital>>8), byte(digital>>16)
+		return
+	}
+	binary.LittleEndian.PutUint16(b, uint16(int16(digital)))
+}
+
+// Calibration is a linear conversion from physical to digital values, as found in a
+// device's datasheet: digital = Gain*physical + Offset.
+type Calibration struct {
+	Gain   float64 // Digital units per physical unit
+	Offset float64 // Digital value of a physical zero
+}
+
+// encodeSamples encodes physical sample values into b as little-endian digital values
+// of sampleBytes bytes each using the calibration, rounding to the nearest digital
+// value. Samples that map outside the signal's digital range are an error.
+func (c Calibration) encodeSamples(b []byte, signal SignalHeader, samples []float64, sampleBytes int) error {
+	for i, sample := range samples {
+		digitalValue := math.Round(c.Gain*sample + c.Offset)
+		if digitalValue < float64(signal.DigitalMin) || digitalValue > float64(signal.DigitalMax) {
+			return fmt.Errorf("sample %d: physical value %g maps to digital value %g outside [%d, %d]",
+				i, sample, digitalValue, signal.DigitalMin, signal.DigitalMax)
+		}
+		encodeSample(b[i*sampleBytes:], int32(digitalValue), sampleBytes)
+	}
+	return nil
+}
+
+// Warnings returns the warnings collected while writing, such as oversized records
+// when the writer was created with WithOversizeRecordWarnings.
+func (ew *Writer) Warnings() []error {
+	return ew.warnings
+}
+
+// RecordsWritten returns the number of data records written so far.
+func (ew *Writer) RecordsWritten() int {
+	return ew.dataRecords
+}
+
+// WriteFrom reads sampleCount little-endian float64 samples for the given signal from r.
+// Samples are buffered per signal and data records are only written once every signal
+// has at least a full record's worth of samples available, so producers are free to
+// deliver each signal in whatever chunk sizes are convenient. Any samples that do not
+// make up a complete record remain buffered; on Close they are written out as a final
+// record, padded with each signal's physical minimum.
+func (ew *Writer) WriteFrom(signalIndex int, r io.Reader, sampleCount int) error {
+	if signalIndex < 0 || signalIndex >= ew.hdr.SignalCount {
+		return fmt.Errorf("%w: %d", ErrSignalIndexOutOfRange, signalIndex)
+	}
+	if sampleCount < 0 {
+		return fmt.Errorf("invalid sample count %d", sampleCount)
+	}
+
+	samples := make([]float64, sampleCount)
+	if err := binary.Read(r, binary.LittleEndian, samples); err != nil {
+		return fmt.Errorf("error reading samples: %w", err)
+	}
+
+	return ew.WriteContinuous(signalIndex, samples)
+}
+
+// WriteContinuous appends an arbitrarily long run of samples for one signal, splitting
+// it into data records automatically. Like WriteFrom, a record is only written once
+// every signal has a full record's worth of samples buffered, which keeps the signals
+// aligned in time. A partial tail remains buffered until more samples arrive or the
+// writer is closed, at which point it is padded with the signal's physical minimum (or
+// the value given by WithPadValue).
+func (ew *Writer) WriteContinuous(signalIndex int, samples []float64) error {
+	if signalIndex < 0 || signalIndex >= ew.hdr.SignalCount {
+		return fmt.Errorf("%w: %d", ErrSignalIndexOutOfRange, signalIndex)
+	}
+
+	ew.pending[signalIndex] = append(ew.pending[signalIndex], samples...)
+
+	return ew.writePending()
+}
+
+// writePending writes as many complete data records as the buffered samples allow.
+func (ew *Writer) writePending() error {
+	var recordSamples int
+	for _, signal := range ew.hdr.Signals {
+		if !signal.IsAnnotations() {
+			recordSamples += signal.SamplesPerRecord
+		}
+	}
+	if recordSamples == 0 {
+		return nil
+	}
+
+	for {
+		record := make([][]float64, ew.hdr.SignalCount)
+		for i := 0; i < ew.hdr.SignalCount; i++ {
+			if ew.hdr.Signals[i].IsAnnotations() {
+				continue
+			}
+			samplesPerRecord := ew.hdr.Signals[i].SamplesPerRecord
+			if len(ew.pending[i]) < samplesPerRecord {
+				return nil
+			}
+			record[i] = ew.pending[i][:samplesPerRecord]
+		}
+
+		if err := ew.WriteRecord(record); err != nil {
+			return err
+		}
+
+		for i := 0; i < ew.hdr.SignalCount; i++ {
+			if ew.hdr.Signals[i].IsAnnotations() {
+				continue
+			}
+			ew.pending[i] = ew.pending[i][ew.hdr.Signals[i].SamplesPerRecord:]
+		}
+	}
+}
+
+// flushPending pads the buffered samples out to whole records and writes them.
+func (ew *Writer) flushPending() error {
+	var records int
+	for i := 0; i < ew.hdr.SignalCount; i++ {
+		samplesPerRecord := ew.hdr.Signals[i].SamplesPerRecord
+		if samplesPerRecord == 0 || ew.hdr.Signals[i].IsAnnotations() {
+			continue
+		}
+		n := (len(ew.pending[i]) + samplesPerRecord - 1) / samplesPerRecord
+		if n > records {
+			records = n
+		}
+	}
+	if records == 0 {
+		return nil
+	}
+
+	for i := 0; i < ew.hdr.SignalCount; i++ {
+		signal := ew.hdr.Signals[i]
+		if signal.IsAnnotations() {
+			continue
+		}
+		pad := signal.PhysicalMin
+		if ew.padWithValue {
+			pad = ew.padValue
+		}
+		for len(ew.pending[i]) < records*signal.SamplesPerRecord {
+			ew.pending[i] = append(ew.pending[i], pad)
+		}
+	}
+
+	return ew.writePending()
+}
+
+// writeDataRecords updates the number of data records in the header, leaving the rest
+// of the header as is, then returns to the end of the data.
+func (ew *Writer) writeDataRecords() error {
+	if _, err := ew.w.Seek(int64(headerLayout(0)["DataRecords"]), io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(ew.w, fmt.Sprintf("%-8d", ew.hdr.DataRecords)); err != nil {
+		return err
+	}
+
+	_, err := ew.w.Seek(int64(ew.hdr.HeaderBytes)+int64(ew.dataRecords)*ew.hdr.recordSize(ew.hdr.sampleBytes()), io.SeekStart)
+	return err
+}
+
 // WriteHeader writes an EDF header to the given writer.
 func (ew *Writer) writeHeader() error {
 	// Rewind to the beginning of the file.
@@ -95,41 +687,76 @@ func (ew *Writer) writeHeader() error {
 		return err
 	}
 
-	writer := bufio.NewWriter(ew.w)
+	if err := encodeHeader(ew.bw, ew.hdr, ew.verifyHeaderLayout); err != nil {
+		return err
+	}
+
+	// Ensure all data is flushed to the underlying writer
+	return ew.bw.Flush()
+}
+
+// Marshal encodes the header as the 256+N*256 byte header block written at the start of
+// an EDF file, exactly as a Writer would write it, with the encoded HeaderBytes field
+// matching the size of the block. As with Create, the header is validated and the
+// continuity marker for its Type is written to the reserved field, and the per-signal
+// reserved fields are left blank. The number of data records is written as is. The
+// header itself is left unchanged.
+func (h *Header) Marshal() ([]byte, error) {
+	hdr := *h
+	if hdr.Type != Plain {
+		hdr.Reserved = continuityMarker(hdr.Type, hdr.Version)
+	}
+
+	if err := ValidateHeader(&hdr); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := encodeHeader(&buf, &hdr, true); err != nil {
+		return nil, err
+	}
 
+	return buf.Bytes(), nil
+}
+
+// encodeHeader writes the header block for hdr to w, setting hdr.HeaderBytes. If
+// verifyLayout is set, every field is checked to land at the offset mandated by the
+// standard.
+func encodeHeader(w io.Writer, hdr *Header, verifyLayout bool) error {
+	var layout map[string]int
+	if verifyLayout {
+		layout = headerLayout(hdr.SignalCount)
+	}
+
+	var offset int
 	writeChecked := func(fieldName, value string, length int) error {
 		if len(value) > length {
 			return fmt.Errorf("%s '%s' is too long (%d > %d)", fieldName, value, len(value), length)
 		}
-		_, err := writer.WriteString(fmt.Sprintf("%-*s", length, value))
-		return err
-	}
-
-	formatPhysicalValue := func(val float64) (string, error) {
-		s := fmt.Sprintf("%.2f", val)
-		if len(s) > 8 {
-			s = fmt.Sprintf("%.0f", val)
-			if len(s) > 8 {
-				return "", fmt.Errorf("physical value %.2f too long to fit in 8 bytes", val)
+		if layout != nil {
+			if expected, ok := layout[fieldName]; !ok || offset != expected {
+				return fmt.Errorf("%s written at offset %d, expected %d", fieldName, offset, expected)
 			}
 		}
-		return fmt.Sprintf("%-8s", s), nil
+		n, err := io.WriteString(w, fmt.Sprintf("%-*s", length, value))
+		offset += n
+		return err
 	}
 
 	// Write version, patient and recording IDs
-	if err := writeChecked("Version", string(ew.hdr.Version), 8); err != nil {
+	if err := writeChecked("Version", string(hdr.Version), 8); err != nil {
 		return err
 	}
-	if err := writeChecked("PatientID", ew.hdr.PatientID, 80); err != nil {
+	if err := writeChecked("PatientID", hdr.PatientID, 80); err != nil {
 		return err
 	}
-	if err := writeChecked("RecordingID", ew.hdr.RecordingID, 80); err != nil {
+	if err := writeChecked("RecordingID", hdr.RecordingID, 80); err != nil {
 		return err
 	}
 
 	// Write start date and time
-	dateStr := ew.hdr.StartTime.Format("02.01.06")
-	timeStr := ew.hdr.StartTime.Format("15.04.05")
+	dateStr := hdr.StartTime.Format("02.01.06")
+	timeStr := hdr.StartTime.Format("15.04.05")
 	if err := writeChecked("StartDate", dateStr, 8); err != nil {
 		return err
 	}
@@ -138,101 +765,202 @@ func (ew *Writer) writeHeader() error {
 	}
 
 	// Write header bytes, data records, etc.
-	ew.hdr.HeaderBytes = 256 + (ew.hdr.SignalCount * 256)
-	if err := writeChecked("HeaderBytes", fmt.Sprintf("%d", ew.hdr.HeaderBytes), 8); err != nil {
+	hdr.HeaderBytes = 256 + (hdr.SignalCount * 256)
+	if err := writeChecked("HeaderBytes", fmt.Sprintf("%d", hdr.HeaderBytes), 8); err != nil {
 		return err
 	}
 
-	// Write 44 empty reserved bytes.
-	if err := writeChecked("Reserved", "", 44); err != nil {
+	// Write the reserved field, which holds the EDF+ continuity marker and vendor tag.
+	reserved, err := joinReserved(hdr.Reserved, hdr.VendorTag)
+	if err != nil {
+		return err
+	}
+	if err := writeChecked("Reserved", reserved, 44); err != nil {
 		return err
 	}
 
 	// Write the number of data records.
-	if err := writeChecked("DataRecords", fmt.Sprintf("%d", ew.hdr.DataRecords), 8); err != nil {
+	if err := writeChecked("DataRecords", fmt.Sprintf("%d", hdr.DataRecords), 8); err != nil {
 		return err
 	}
 
 	// Write data record duration
-	if err := writeChecked("Duration", fmt.Sprintf("%d", int(math.Ceil(ew.hdr.DataRecordDuration.Seconds()))), 8); err != nil {
+	duration, err := formatDuration(hdr.DataRecordDuration)
+	if err != nil {
+		return err
+	}
+	if err := writeChecked("Duration", duration, 8); err != nil {
 		return err
 	}
 
 	// Write signal count
-	if err := writeChecked("SignalCount", fmt.Sprintf("%d", ew.hdr.SignalCount), 4); err != nil {
+	if err := writeChecked("SignalCount", fmt.Sprintf("%d", hdr.SignalCount), 4); err != nil {
 		return err
 	}
 
-	for i, signal := range ew.hdr.Signals {
+	for i, signal := range hdr.Signals {
 		if err := writeChecked(fmt.Sprintf("Signal[%d].Label", i), signal.Label, 16); err != nil {
 			return err
 		}
 	}
-	for i, signal := range ew.hdr.Signals {
+	for i, signal := range hdr.Signals {
 		if err := writeChecked(fmt.Sprintf("Signal[%d].TransducerType", i), signal.TransducerType, 80); err != nil {
 			return err
 		}
 	}
-	for i, signal := range ew.hdr.Signals {
+	for i, signal := range hdr.Signals {
 		if err := writeChecked(fmt.Sprintf("Signal[%d].PhysicalDimension", i), signal.PhysicalDimension, 8); err != nil {
 			return err
 		}
 	}
-	for i, signal := range ew.hdr.Signals {
+	for i, signal := range hdr.Signals {
 		str, err := formatPhysicalValue(signal.PhysicalMin)
 		if err != nil {
 			return fmt.Errorf("Signal[%d].PhysicalMin: %w", i, err)
 		}
-		if _, err := writer.WriteString(str); err != nil {
+		if err := writeChecked(fmt.Sprintf("Signal[%d].PhysicalMin", i), str, 8); err != nil {
 			return err
 		}
 	}
-	for i, signal := range ew.hdr.Signals {
+	for i, signal := range hdr.Signals {
 		str, err := formatPhysicalValue(signal.PhysicalMax)
 		if err != nil {
 			return fmt.Errorf("Signal[%d].PhysicalMax: %w", i, err)
 		}
-		if _, err := writer.WriteString(str); err != nil {
+		if err := writeChecked(fmt.Sprintf("Signal[%d].PhysicalMax", i), str, 8); err != nil {
 			return err
 		}
 	}
-	for i, signal := range ew.hdr.Signals {
+	for i, signal := range hdr.Signals {
 		if err := writeChecked(fmt.Sprintf("Signal[%d].DigitalMin", i), fmt.Sprintf("%d", signal.DigitalMin), 8); err != nil {
 			return err
 		}
 	}
-	for i, signal := range ew.hdr.Signals {
+	for i, signal := range hdr.Signals {
 		if err := writeChecked(fmt.Sprintf("Signal[%d].DigitalMax", i), fmt.Sprintf("%d", signal.DigitalMax), 8); err != nil {
 			return err
 		}
 	}
-	for i, signal := range ew.hdr.Signals {
+	for i, signal := range hdr.Signals {
 		if err := writeChecked(fmt.Sprintf("Signal[%d].Prefiltering", i), signal.Prefiltering, 80); err != nil {
 			return err
 		}
 	}
-	for i, signal := range ew.hdr.Signals {
+	for i, signal := range hdr.Signals {
 		if err := writeChecked(fmt.Sprintf("Signal[%d].SamplesPerRecord", i), fmt.Sprintf("%d", signal.SamplesPerRecord), 8); err != nil {
 			return err
 		}
 	}
 
 	// Reserved for future use
-	for i := range ew.hdr.Signals {
+	for i := range hdr.Signals {
 		if err := writeChecked(fmt.Sprintf("Signal[%d].Reserved", i), "", 32); err != nil {
 			return err
 		}
 	}
 
-	// Ensure all data is flushed to the underlying writer
-	return writer.Flush()
+	return nil
+}
+
+// canonicalDimensions maps alternative spellings of physical dimensions to the form
+// recommended by the EDF+ standard. Keys are lower case.
+var canonicalDimensions = map[string]string{
+	"v":          "V",
+	"volt":       "V",
+	"volts":      "V",
+	"mv":         "mV",
+	"millivolt":  "mV",
+	"millivolts": "mV",
+	"uv":         "uV",
+	"µv":         "uV",
+	"microvolt":  "uV",
+	"microvolts": "uV",
+	"degc":       "degC",
+	"celsius":    "degC",
+	"cmh2o":      "cmH2O",
+	"l/s":        "L/s",
+	"l/min":      "L/min",
+	"%":          "%",
+	"percent":    "%",
+	"ohm":        "Ohm",
+	"ohms":       "Ohm",
+	"kohm":       "kOhm",
+	"kohms":      "kOhm",
+	"mmhg":       "mmHg",
+}
+
+// formatPhysicalValue formats a physical minimum or maximum for its 8 byte header field,
+// to two decimal places if they fit.
+func formatPhysicalValue(val float64) (string, error) {
+	s := fmt.Sprintf("%.2f", val)
+	if len(s) > 8 {
+		s = fmt.Sprintf("%.0f", val)
+		if len(s) > 8 {
+			return "", fmt.Errorf("physical value %.2f too long to fit in 8 bytes", val)
+		}
+	}
+	return s, nil
+}
+
+// formatDuration formats a data record duration in seconds for the 8 byte header field,
+// preserving fractional seconds (e.g. 0.25) to as many decimal places as fit.
+func formatDuration(d time.Duration) (string, error) {
+	s := strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+	if len(s) <= 8 {
+		return s, nil
+	}
+
+	whole, _, _ := strings.Cut(s, ".")
+	if len(whole) > 8 {
+		return "", fmt.Errorf("data record duration %s too long to fit in 8 bytes", d)
+	}
+
+	// Round to the decimal places that fit, dropping any trailing zeros this leaves.
+	decimals := 8 - len(whole) - 1
+	if decimals < 0 {
+		decimals = 0
+	}
+	s = strconv.FormatFloat(d.Seconds(), 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s, nil
 }
 
-// convertPhysicalToDigital converts a physical value to a digital value using the calibration factors.
-func convertPhysicalToDigital(physical float64, pmin, pmax float64, dmin, dmax int) int16 {
+// canonicalDimension returns the canonical spelling of a physical dimension, or the
+// dimension unchanged if it isn't recognized.
+func canonicalDimension(dimension string) string {
+	if canonical, ok := canonicalDimensions[strings.ToLower(strings.TrimSpace(dimension))]; ok {
+		return canonical
+	}
+	return dimension
+}
+
+// convertPhysicalToDigital converts a physical value to a digital value using the calibration factors,
+// rounding to the nearest digital value rather than truncating, which would bias values towards zero.
+// Values beyond the digital range are clamped to it, so an overshoot can't wrap around.
+func convertPhysicalToDigital(physical float64, pmin, pmax float64, dmin, dmax int) int32 {
+	digital := physicalToDigital(physical, pmin, pmax, dmin, dmax)
+	return int32(math.Max(float64(dmin), math.Min(float64(dmax), digital)))
+}
+
+// physicalToDigital converts a physical value to a digital value using the calibration factors,
+// rounding to the nearest digital value but without clamping it to the digital range.
+func physicalToDigital(physical float64, pmin, pmax float64, dmin, dmax int) float64 {
 	if pmax == pmin {
 		return 0 // Avoid division by zero
 	}
-	digital := ((physical - pmin) * (float64(dmax - dmin)) / (pmax - pmin)) + float64(dmin)
-	return int16(digital)
+	return math.Round(((physical - pmin) * (float64(dmax - dmin)) / (pmax - pmin)) + float64(dmin))
+}
+
+// checkDigitalRange checks that physical sample values map within the signal's digital range.
+func checkDigitalRange(signal SignalHeader, samples []float64) error {
+	for i, sample := range samples {
+		digital := physicalToDigital(sample, signal.PhysicalMin, signal.PhysicalMax, signal.DigitalMin, signal.DigitalMax)
+		if digital < float64(signal.DigitalMin) || digital > float64(signal.DigitalMax) {
+			return fmt.Errorf("sample %d: physical value %g is outside the physical range [%g, %g]",
+				i, sample, signal.PhysicalMin, signal.PhysicalMax)
+		}
+	}
+	return nil
 }
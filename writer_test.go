@@ -10,13 +10,18 @@
 package edf_test
 
 import (
+	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/OpenPSG/edf"
+	"github.com/OpenPSG/edf/edftest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -90,12 +95,1339 @@ func TestWriter(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 512, n)
 
-	// Verify the samples match what was written.
+	// Verify the samples match what was written, with those beyond the physical
+	// maximum clamped to it.
 	for i := range samples {
-		require.InDelta(t, float64(i), samples[i], 1.0)
+		require.InDelta(t, math.Min(float64(i), 500), samples[i], 1.0)
 	}
 
 	// Reader should now return EOF
 	_, err = sr.Read(samples)
 	require.Equal(t, io.EOF, err)
 }
+
+func TestWriterWriteFrom(t *testing.T) {
+	f, err := os.OpenFile(filepath.Join(t.TempDir(), "test.edf"), os.O_RDWR|os.O_CREATE, 0o644)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, f.Close())
+	})
+
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  256,
+			},
+			{
+				Label:             "Resp",
+				PhysicalDimension: "mV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  32,
+			},
+		},
+	}
+
+	ew, err := edf.Create(f, hdr)
+	require.NoError(t, err)
+
+	// generate streams n samples of a ramp, in little-endian float64 form.
+	generate := func(n int) io.Reader {
+		pr, pw := io.Pipe()
+		go func() {
+			for i := 0; i < n; i++ {
+				if err := binary.Write(pw, binary.LittleEndian, float64(i%400)); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+			pw.Close()
+		}()
+		return pr
+	}
+
+	// Deliver the signals in chunks that don't line up with the record boundaries.
+	eeg := generate(3 * 256)
+	resp := generate(3*32 - 10)
+	for i := 0; i < 3*256; i += 100 {
+		count := 100
+		if i+count > 3*256 {
+			count = 3*256 - i
+		}
+		require.NoError(t, ew.WriteFrom(0, eeg, count))
+	}
+	require.NoError(t, ew.WriteFrom(1, resp, 3*32-10))
+	require.ErrorContains(t, ew.WriteFrom(1, resp, -1), "invalid sample count -1")
+
+	require.NoError(t, ew.Close())
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	samples := make([]float64, 3*256)
+	n, err := sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, 3*256, n)
+
+	for i := range samples {
+		require.InDelta(t, float64(i%400), samples[i], 1.0)
+	}
+
+	sr, err = er.Signal(1)
+	require.NoError(t, err)
+
+	samples = make([]float64, 3*32)
+	n, err = sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, 3*32, n)
+
+	for i := 0; i < 3*32-10; i++ {
+		require.InDelta(t, float64(i), samples[i], 1.0)
+	}
+
+	// The final partial record is padded with the physical minimum.
+	for i := 3*32 - 10; i < 3*32; i++ {
+		require.InDelta(t, -500, samples[i], 1.0)
+	}
+}
+
+func TestWriterWriteContinuous(t *testing.T) {
+	f := createTestFile(t)
+
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -500,
+				PhysicalMax:      500,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 10,
+			},
+		},
+	}
+
+	ew, err := edf.Create(f, hdr)
+	require.NoError(t, err)
+
+	// An odd-length run that doesn't divide into whole records.
+	samples := make([]float64, 37)
+	for i := range samples {
+		samples[i] = float64(i)
+	}
+
+	require.NoError(t, ew.WriteContinuous(0, samples))
+	require.Equal(t, 3, ew.RecordsWritten())
+
+	require.NoError(t, ew.Close())
+	require.Equal(t, 4, ew.RecordsWritten())
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+	require.Equal(t, 4, er.Header().DataRecords)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	read := make([]float64, 40)
+	n, err := sr.Read(read)
+	require.NoError(t, err)
+	require.Equal(t, 40, n)
+
+	for i := range samples {
+		require.InDelta(t, samples[i], read[i], 1.0)
+	}
+	for i := len(samples); i < len(read); i++ {
+		require.InDelta(t, -500, read[i], 1.0)
+	}
+
+	require.Error(t, ew.WriteContinuous(1, samples))
+}
+
+func TestWriteSignals(t *testing.T) {
+	f := createTestFile(t)
+
+	// 2.5 seconds of data at 100 Hz, so the final record is padded.
+	signals := map[string][]float64{
+		"Flow":  make([]float64, 250),
+		"Press": make([]float64, 250),
+	}
+	for i := 0; i < 250; i++ {
+		signals["Flow"][i] = math.Sin(float64(i) / 10)
+		signals["Press"][i] = 4 + float64(i)/25
+	}
+
+	start := time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC)
+	require.NoError(t, edf.WriteSignals(f, signals, 100, start))
+
+	_, err := f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	meta := er.SignalMetadata()
+	require.Len(t, meta, 2)
+	require.Equal(t, "Flow", meta[0].Label)
+	require.Equal(t, "Press", meta[1].Label)
+	require.InDelta(t, 100.0, meta[0].SampleRate, 1e-9)
+
+	for i, label := range []string{"Flow", "Press"} {
+		sr, err := er.Signal(i)
+		require.NoError(t, err)
+
+		samples := make([]float64, 300)
+		n, err := sr.Read(samples)
+		require.NoError(t, err)
+		require.Equal(t, 300, n)
+
+		for j, want := range signals[label] {
+			require.InDelta(t, want, samples[j], meta[i].Resolution)
+		}
+	}
+}
+
+func TestWriteSignalsHighRate(t *testing.T) {
+	f := createTestFile(t)
+
+	// One second records of 4 signals at 8192 Hz would need 65536 bytes.
+	signals := make(map[string][]float64)
+	for _, label := range []string{"EEG C3", "EEG C4", "EEG O1", "EEG O2"} {
+		samples := make([]float64, 8192)
+		for i := range samples {
+			samples[i] = math.Sin(float64(i) / 100)
+		}
+		signals[label] = samples
+	}
+
+	start := time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC)
+	require.NoError(t, edf.WriteSignals(f, signals, 8192, start))
+
+	_, err := f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	hdr := er.Header()
+	require.Equal(t, 500*time.Millisecond, hdr.DataRecordDuration)
+	require.Equal(t, 2, hdr.DataRecords)
+	require.Equal(t, 4096, hdr.Signals[0].SamplesPerRecord)
+
+	sr, err := er.Signal(3)
+	require.NoError(t, err)
+
+	samples := make([]float64, 8192)
+	n, err := sr.Read(samples)
+	require.NoError(t, err)
+	require.Equal(t, 8192, n)
+
+	for i, want := range signals["EEG O2"] {
+		require.InDelta(t, want, samples[i], er.SignalMetadata()[3].Resolution)
+	}
+}
+
+func TestWritePadded(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Flow",
+				PhysicalMin:      -100,
+				PhysicalMax:      100,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 10,
+			},
+			{
+				Label:            "SpO2",
+				PhysicalMin:      0,
+				PhysicalMax:      100,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 1,
+			},
+		},
+	}
+
+	// The oximeter dropped out after 2 seconds, the flow sensor ran for 3.5 seconds.
+	flow := make([]float64, 35)
+	for i := range flow {
+		flow[i] = float64(i)
+	}
+	spo2 := []float64{97, 96}
+
+	t.Run("Physical minimum", func(t *testing.T) {
+		f := createTestFile(t)
+
+		padding, err := edf.WritePadded(f, hdr, [][]float64{flow, spo2})
+		require.NoError(t, err)
+		require.Equal(t, []int{5, 2}, padding)
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		er, err := edf.Open(f)
+		require.NoError(t, err)
+		require.Equal(t, 4, er.Header().DataRecords)
+
+		samples, err := er.ReadAll(0)
+		require.NoError(t, err)
+		require.Len(t, samples, 40)
+		require.InDelta(t, 34, samples[34], 0.1)
+		require.InDelta(t, -100, samples[39], 0.1)
+
+		samples, err = er.ReadAll(1)
+		require.NoError(t, err)
+		require.Len(t, samples, 4)
+		require.InDelta(t, 96, samples[1], 0.1)
+		require.InDelta(t, 0, samples[3], 0.1)
+	})
+
+	t.Run("Pad value", func(t *testing.T) {
+		f := createTestFile(t)
+
+		_, err := edf.WritePadded(f, hdr, [][]float64{flow, spo2}, edf.WithPadValue(50))
+		require.NoError(t, err)
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		er, err := edf.Open(f)
+		require.NoError(t, err)
+
+		samples, err := er.ReadAll(1)
+		require.NoError(t, err)
+		require.InDelta(t, 50, samples[2], 0.1)
+		require.InDelta(t, 50, samples[3], 0.1)
+	})
+
+	t.Run("Annotations", func(t *testing.T) {
+		hdr := hdr
+		hdr.Reserved = "EDF+C"
+		hdr.SignalCount = 3
+		hdr.Signals = append(append([]edf.SignalHeader(nil), hdr.Signals...), edf.SignalHeader{
+			Label:            edf.AnnotationsLabel,
+			PhysicalMin:      -1,
+			PhysicalMax:      1,
+			DigitalMin:       -32768,
+			DigitalMax:       32767,
+			SamplesPerRecord: 30,
+		})
+
+		// Annotation signals aren't padded.
+		padding, err := edf.WritePadded(createTestFile(t), hdr, [][]float64{flow, spo2, nil})
+		require.NoError(t, err)
+		require.Equal(t, []int{5, 2, 0}, padding)
+	})
+
+	t.Run("Signal count mismatch", func(t *testing.T) {
+		_, err := edf.WritePadded(createTestFile(t), hdr, [][]float64{flow})
+		require.Error(t, err)
+	})
+}
+
+func TestWriterUnknownPlaceholders(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "ECG",
+				PhysicalMin:      -5,
+				PhysicalMax:      5,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 1,
+			},
+		},
+	}
+
+	readIDs := func(f *os.File) (string, string) {
+		b := make([]byte, 160)
+		_, err := f.ReadAt(b, 8)
+		require.NoError(t, err)
+		return string(b[:80]), string(b[80:])
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		f := createTestFile(t)
+
+		ew, err := edf.Create(f, hdr)
+		require.NoError(t, err)
+		require.NoError(t, ew.Close())
+
+		patientID, recordingID := readIDs(f)
+		require.Equal(t, strings.Repeat(" ", 80), patientID)
+		require.Equal(t, strings.Repeat(" ", 80), recordingID)
+	})
+
+	t.Run("Placeholders", func(t *testing.T) {
+		f := createTestFile(t)
+
+		ew, err := edf.Create(f, hdr, edf.WithUnknownPlaceholders())
+		require.NoError(t, err)
+		require.NoError(t, ew.Close())
+
+		patientID, recordingID := readIDs(f)
+		require.Equal(t, "X X X X", strings.TrimSpace(patientID))
+		require.Equal(t, "Startdate 02-JAN-2024 X X X", strings.TrimSpace(recordingID))
+	})
+}
+
+func TestWriterHeaderLayoutCheck(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		PatientID:          "Patient X",
+		RecordingID:        "Recording 1",
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -500,
+				PhysicalMax:       500,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  256,
+			},
+			{
+				Label:             "Resp",
+				PhysicalDimension: "mV",
+				PhysicalMin:       -5,
+				PhysicalMax:       5,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				SamplesPerRecord:  32,
+			},
+		},
+	}
+
+	f := createTestFile(t)
+
+	ew, err := edf.Create(f, hdr, edf.WithHeaderLayoutCheck())
+	require.NoError(t, err)
+	require.NoError(t, ew.Close())
+
+	field := func(offset, width int) string {
+		b := make([]byte, width)
+		_, err := f.ReadAt(b, int64(offset))
+		require.NoError(t, err)
+		return strings.TrimSpace(string(b))
+	}
+
+	require.Equal(t, "Patient X", field(8, 80))
+	require.Equal(t, "12.12.24", field(168, 8))
+	require.Equal(t, "768", field(184, 8))
+	require.Equal(t, "0", field(236, 8))
+	require.Equal(t, "2", field(252, 4))
+	require.Equal(t, "Resp", field(272, 16))
+	require.Equal(t, "uV", field(448, 8))
+	require.Equal(t, "-5.00", field(472, 8))
+	require.Equal(t, "2047", field(512, 8))
+	require.Equal(t, "32", field(696, 8))
+}
+
+func TestWriterCanonicalDimensions(t *testing.T) {
+	dimensions := map[string]string{
+		"UV":      "uV",
+		"uv":      "uV",
+		"Volt":    "V",
+		"MV":      "mV",
+		"degc":    "degC",
+		"cmh2o":   "cmH2O",
+		"bpm":     "bpm",
+		"furlong": "furlong",
+	}
+
+	for dimension, want := range dimensions {
+		hdr := edf.Header{
+			Version:            edf.Version0,
+			StartTime:          time.Now(),
+			DataRecordDuration: time.Second,
+			SignalCount:        1,
+			Signals: []edf.SignalHeader{
+				{
+					Label:             "Signal",
+					PhysicalDimension: dimension,
+					PhysicalMin:       -1,
+					PhysicalMax:       1,
+					DigitalMin:        -2048,
+					DigitalMax:        2047,
+					SamplesPerRecord:  1,
+				},
+			},
+		}
+
+		for _, canonicalize := range []bool{false, true} {
+			f := createTestFile(t)
+
+			var opts []edf.WriterOption
+			if canonicalize {
+				opts = append(opts, edf.WithCanonicalDimensions())
+			}
+
+			ew, err := edf.Create(f, hdr, opts...)
+			require.NoError(t, err)
+			require.NoError(t, ew.Close())
+
+			_, err = f.Seek(0, io.SeekStart)
+			require.NoError(t, err)
+
+			er, err := edf.Open(f)
+			require.NoError(t, err)
+
+			if canonicalize {
+				require.Equal(t, want, er.SignalMetadata()[0].Unit)
+			} else {
+				require.Equal(t, dimension, er.SignalMetadata()[0].Unit)
+			}
+		}
+
+		// The caller's header is never modified.
+		require.Equal(t, dimension, hdr.Signals[0].PhysicalDimension)
+	}
+}
+
+func TestWriterRecordsWritten(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -1,
+				PhysicalMax:      1,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	ew, err := edf.Create(createTestFile(t), hdr)
+	require.NoError(t, err)
+	require.Equal(t, 0, ew.RecordsWritten())
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, ew.WriteRecord([][]float64{make([]float64, 4)}))
+		require.Equal(t, i, ew.RecordsWritten())
+	}
+
+	require.NoError(t, ew.Close())
+	require.Equal(t, 3, ew.RecordsWritten())
+}
+
+func TestWriterRecordSizeLimit(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -1,
+				PhysicalMax:      1,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 40000,
+			},
+		},
+	}
+
+	record := [][]float64{make([]float64, 40000)}
+
+	t.Run("Error", func(t *testing.T) {
+		ew, err := edf.Create(createTestFile(t), hdr)
+		require.NoError(t, err)
+
+		var warning *edf.RecordSizeWarning
+		require.ErrorAs(t, ew.WriteRecord(record), &warning)
+		require.Equal(t, 80000, warning.Size)
+		require.Equal(t, edf.DefaultMaxRecordSize, warning.Limit)
+		require.Equal(t, 0, ew.RecordsWritten())
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		ew, err := edf.Create(createTestFile(t), hdr, edf.WithMaxRecordSize(100000))
+		require.NoError(t, err)
+
+		require.NoError(t, ew.WriteRecord(record))
+		require.Empty(t, ew.Warnings())
+	})
+
+	t.Run("Warn", func(t *testing.T) {
+		ew, err := edf.Create(createTestFile(t), hdr, edf.WithOversizeRecordWarnings())
+		require.NoError(t, err)
+
+		require.NoError(t, ew.WriteRecord(record))
+		require.NoError(t, ew.WriteRecord(record))
+		require.Equal(t, 2, ew.RecordsWritten())
+
+		warnings := ew.Warnings()
+		require.Len(t, warnings, 2)
+		require.EqualError(t, warnings[1], "data record 1 too large: 80000 bytes, max is 61440 bytes")
+	})
+}
+
+func TestNewSignal(t *testing.T) {
+	signal, err := edf.NewSignal("SpO2", "%", 0, 100, 0.5, 30*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, edf.SignalHeader{
+		Label:             "SpO2",
+		PhysicalDimension: "%",
+		PhysicalMin:       0,
+		PhysicalMax:       100,
+		DigitalMin:        -32768,
+		DigitalMax:        32767,
+		SamplesPerRecord:  15,
+	}, signal)
+
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: 30 * time.Second,
+		SignalCount:        1,
+		Signals:            []edf.SignalHeader{signal},
+	}
+
+	records := make([][][]float64, 2)
+	for i := range records {
+		samples := make([]float64, signal.SamplesPerRecord)
+		for j := range samples {
+			samples[j] = 90 + float64(i*15+j)/3
+		}
+		records[i] = [][]float64{samples}
+	}
+
+	edftest.AssertRoundTrip(t, hdr, records)
+
+	_, err = edf.NewSignal("EEG", "uV", -500, 500, 256, 300*time.Millisecond)
+	require.Error(t, err)
+
+	_, err = edf.NewSignal("EEG", "uV", 500, 500, 256, time.Second)
+	require.Error(t, err)
+}
+
+func TestWriterCalibration(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Derived",
+				PhysicalMin:      -500,
+				PhysicalMax:      500,
+				DigitalMin:       -2000,
+				DigitalMax:       2000,
+				SamplesPerRecord: 5,
+			},
+			{
+				Label:            "Calibrated",
+				PhysicalMin:      -500,
+				PhysicalMax:      500,
+				DigitalMin:       -2000,
+				DigitalMax:       2000,
+				SamplesPerRecord: 5,
+			},
+		},
+	}
+
+	// The same calibration the header's ranges describe, 4 digital units per uV.
+	calibration := edf.Calibration{Gain: 4, Offset: 0}
+
+	samples := []float64{-500, -123.25, 0, 42.5, 500}
+
+	f := createTestFile(t)
+	ew, err := edf.Create(f, hdr, edf.WithCalibration(map[int]edf.Calibration{1: calibration}))
+	require.NoError(t, err)
+	require.NoError(t, ew.WriteRecord([][]float64{samples, samples}))
+	require.NoError(t, ew.Close())
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	derived := make([]int32, 5)
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+	_, err = sr.ReadDigital(derived)
+	require.NoError(t, err)
+
+	calibrated := make([]int32, 5)
+	sr, err = er.Signal(1)
+	require.NoError(t, err)
+	_, err = sr.ReadDigital(calibrated)
+	require.NoError(t, err)
+
+	require.Equal(t, []int32{-2000, -493, 0, 170, 2000}, calibrated)
+	for i := range samples {
+		require.InDelta(t, derived[i], calibrated[i], 1)
+	}
+
+	// Values that the calibration maps outside the digital range are rejected.
+	ew, err = edf.Create(createTestFile(t), hdr, edf.WithCalibration(map[int]edf.Calibration{1: {Gain: 4, Offset: 100}}))
+	require.NoError(t, err)
+	require.ErrorContains(t, ew.WriteRecord([][]float64{samples, samples}), "signal 1: sample 4")
+}
+
+func TestWriterVendorTag(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		Reserved:           "EDF+C",
+		VendorTag:          "acme-recorder/1.2.3",
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -1,
+				PhysicalMax:      1,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 1,
+			},
+		},
+	}
+
+	er, err := edf.Open(writeTestFile(t, hdr, nil))
+	require.NoError(t, err)
+	require.Equal(t, "EDF+C", er.Header().Reserved)
+	require.Equal(t, "acme-recorder/1.2.3", er.Header().VendorTag)
+
+	// Plain EDF files have no continuity marker, but can still carry a tag.
+	hdr.Reserved = ""
+	er, err = edf.Open(writeTestFile(t, hdr, nil))
+	require.NoError(t, err)
+	require.Equal(t, "", er.Header().Reserved)
+	require.Equal(t, "acme-recorder/1.2.3", er.Header().VendorTag)
+
+	// Free text in the reserved field of a plain EDF file isn't mistaken for a marker
+	// and vendor tag.
+	hdr.VendorTag = ""
+	f := writeTestFile(t, hdr, nil)
+	patchTestFile(t, f, 192, fmt.Sprintf("%-44s", "Nihon Kohden export"))
+
+	er, err = edf.Open(f)
+	require.NoError(t, err)
+	require.Equal(t, "Nihon Kohden export", er.Header().Reserved)
+	require.Empty(t, er.Header().VendorTag)
+	require.Equal(t, edf.Plain, er.Header().Type)
+
+	hdr.VendorTag = strings.Repeat("x", 39)
+	_, err = edf.Create(createTestFile(t), hdr)
+	require.ErrorContains(t, err, "longer than 38 bytes")
+}
+
+func TestWriterNonFiniteSamples(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -500,
+				PhysicalMax:      500,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		ew, err := edf.Create(createTestFile(t), hdr)
+		require.NoError(t, err)
+
+		err = ew.WriteRecord([][]float64{{0, 1, value, 3}})
+		require.ErrorContains(t, err, "signal 0: sample 2 is")
+		require.Equal(t, 0, ew.RecordsWritten())
+	}
+
+	f := createTestFile(t)
+	ew, err := edf.Create(f, hdr, edf.WithNonFiniteFill(-500))
+	require.NoError(t, err)
+
+	samples := []float64{math.NaN(), 100, math.Inf(1), math.Inf(-1)}
+	require.NoError(t, ew.WriteRecord([][]float64{samples}))
+	require.NoError(t, ew.Close())
+
+	// The caller's samples are left untouched.
+	require.True(t, math.IsNaN(samples[0]))
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	read := make([]float64, 4)
+	_, err = sr.Read(read)
+	require.NoError(t, err)
+
+	for i, want := range []float64{-500, 100, -500, -500} {
+		require.InDelta(t, want, read[i], 1.0)
+	}
+}
+
+func TestWriterBDF(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.VersionBDF,
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fp1",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -262144,
+				PhysicalMax:       262143,
+				DigitalMin:        -8388608,
+				DigitalMax:        8388607,
+				SamplesPerRecord:  256,
+			},
+		},
+	}
+
+	// Values finer than a 16-bit file could resolve over the same range.
+	records := make([][][]float64, 2)
+	for i := range records {
+		samples := make([]float64, 256)
+		for j := range samples {
+			samples[j] = 1000*math.Sin(2*math.Pi*float64(i*256+j)/64) + 0.1*float64(j)
+		}
+		records[i] = [][]float64{samples}
+	}
+
+	edftest.AssertRoundTrip(t, hdr, records)
+
+	f := writeTestFile(t, hdr, records)
+
+	info, err := f.Stat()
+	require.NoError(t, err)
+	require.Equal(t, int64(512+2*256*3), info.Size())
+
+	// The record size limit accounts for the wider samples.
+	hdr.Signals[0].SamplesPerRecord = edf.DefaultMaxRecordSize/3 + 1
+
+	ew, err := edf.Create(createTestFile(t), hdr)
+	require.NoError(t, err)
+
+	var warning *edf.RecordSizeWarning
+	require.ErrorAs(t, ew.WriteRecord([][]float64{make([]float64, hdr.Signals[0].SamplesPerRecord)}), &warning)
+	require.Equal(t, 61443, warning.Size)
+}
+
+func TestWriterFractionalRecordDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		field    string
+	}{
+		{100 * time.Millisecond, "0.1     "},
+		{250 * time.Millisecond, "0.25    "},
+		{30 * time.Second, "30      "},
+		{time.Second / 3, "0.333333"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.duration.String(), func(t *testing.T) {
+			hdr := edf.Header{
+				Version:            edf.Version0,
+				StartTime:          time.Now(),
+				DataRecordDuration: tt.duration,
+				SignalCount:        1,
+				Signals: []edf.SignalHeader{
+					{
+						Label:            "Accel X",
+						PhysicalMin:      -8,
+						PhysicalMax:      8,
+						DigitalMin:       -32768,
+						DigitalMax:       32767,
+						SamplesPerRecord: 25,
+					},
+				},
+			}
+
+			f := writeTestFile(t, hdr, nil)
+
+			field := make([]byte, 8)
+			_, err := f.ReadAt(field, 244)
+			require.NoError(t, err)
+			require.Equal(t, tt.field, string(field))
+
+			er, err := edf.Open(f)
+			require.NoError(t, err)
+
+			if tt.duration == time.Second/3 {
+				require.InDelta(t, tt.duration, er.Header().DataRecordDuration, float64(time.Microsecond))
+			} else {
+				require.Equal(t, tt.duration, er.Header().DataRecordDuration)
+			}
+		})
+	}
+}
+
+func TestWriterRoundsToNearestDigitalValue(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -3.3,
+				PhysicalMax:      3.3,
+				DigitalMin:       -2048,
+				DigitalMax:       2047,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	// One quantization step is 6.6/4095 physical units.
+	step := 6.6 / 4095
+
+	er, err := edf.Open(writeTestFile(t, hdr, [][][]float64{
+		{{3.3, -3.3, -3.3 + 0.4*step, -3.3 + 1.4*step}},
+	}))
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	digital := make([]int32, 4)
+	_, err = sr.ReadDigital(digital)
+	require.NoError(t, err)
+
+	require.Equal(t, []int32{2047, -2048, -2048, -2047}, digital)
+}
+
+func TestWriterClampsOutOfRangeSamples(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Now(),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Signal",
+				PhysicalMin:      -100,
+				PhysicalMax:      100,
+				DigitalMin:       -32768,
+				DigitalMax:       32767,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	// Overshoots that would otherwise wrap around the 16-bit range.
+	record := []float64{150, -150, 1e9, 50}
+
+	er, err := edf.Open(writeTestFile(t, hdr, [][][]float64{{record}}))
+	require.NoError(t, err)
+
+	sr, err := er.Signal(0)
+	require.NoError(t, err)
+
+	digital := make([]int32, 4)
+	_, err = sr.ReadDigital(digital)
+	require.NoError(t, err)
+	require.Equal(t, []int32{32767, -32768, 32767, 16383}, digital)
+
+	t.Run("Strict", func(t *testing.T) {
+		ew, err := edf.Create(createTestFile(t), hdr, edf.WithStrictRange())
+		require.NoError(t, err)
+
+		require.ErrorContains(t, ew.WriteRecord([][]float64{record}), "sample 0: physical value 150 is outside the physical range [-100, 100]")
+		require.NoError(t, ew.WriteRecord([][]float64{{100, -100, 0, 50}}))
+	})
+}
+
+func TestOpenWriter(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		PatientID:          "X X X X",
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Flow",
+				PhysicalMin:      -100,
+				PhysicalMax:      100,
+				DigitalMin:       -32768,
+				DigitalMax:       32767,
+				SamplesPerRecord: 4,
+			},
+			{
+				Label:            "SpO2",
+				PhysicalMin:      0,
+				PhysicalMax:      100,
+				DigitalMin:       0,
+				DigitalMax:       1000,
+				SamplesPerRecord: 1,
+			},
+		},
+	}
+
+	f := writeTestFile(t, hdr, [][][]float64{
+		{{1, 2, 3, 4}, {97}},
+		{{5, 6, 7, 8}, {96}},
+	})
+
+	// Simulate a capture interrupted partway through writing a record, before the
+	// header was finalized.
+	_, err := f.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0xAA, 0xAA, 0xAA})
+	require.NoError(t, err)
+	patchTestFile(t, f, 236, "-1      ")
+
+	ew, err := edf.OpenWriter(f)
+	require.NoError(t, err)
+	require.Equal(t, 2, ew.RecordsWritten())
+
+	// Records must match the file's signal layout.
+	require.Error(t, ew.WriteRecord([][]float64{{9, 10, 11, 12}}))
+	require.Error(t, ew.WriteRecord([][]float64{{9, 10, 11}, {95}}))
+
+	require.NoError(t, ew.WriteRecord([][]float64{{9, 10, 11, 12}, {95}}))
+	require.NoError(t, ew.Close())
+
+	info, err := f.Stat()
+	require.NoError(t, err)
+	require.Equal(t, int64(3*256+3*10), info.Size())
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+	require.Equal(t, 3, er.Header().DataRecords)
+	require.Equal(t, "X X X X", er.Header().PatientID)
+
+	flow, err := er.ReadAll(0)
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, flow, 0.01)
+
+	spo2, err := er.ReadAll(1)
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{97, 96, 95}, spo2, 0.1)
+
+	t.Run("NothingAppended", func(t *testing.T) {
+		f := writeTestFile(t, hdr, [][][]float64{
+			{{1, 2, 3, 4}, {97}},
+		})
+
+		_, err := f.Seek(0, io.SeekEnd)
+		require.NoError(t, err)
+		_, err = f.Write([]byte{0xAA, 0xAA, 0xAA})
+		require.NoError(t, err)
+
+		ew, err := edf.OpenWriter(f)
+		require.NoError(t, err)
+		require.NoError(t, ew.Close())
+
+		// The incomplete record is left in place, but not counted.
+		info, err := f.Stat()
+		require.NoError(t, err)
+		require.Equal(t, int64(3*256+10+3), info.Size())
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		er, err := edf.Open(f)
+		require.NoError(t, err)
+		require.Equal(t, 1, er.Header().DataRecords)
+	})
+}
+
+func TestWriterRecordLength(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Flow",
+				PhysicalMin:      -100,
+				PhysicalMax:      100,
+				DigitalMin:       -32768,
+				DigitalMax:       32767,
+				SamplesPerRecord: 4,
+			},
+			{
+				Label:            "SpO2",
+				PhysicalMin:      0,
+				PhysicalMax:      100,
+				DigitalMin:       0,
+				DigitalMax:       1000,
+				SamplesPerRecord: 1,
+			},
+		},
+	}
+
+	f := createTestFile(t)
+	ew, err := edf.Create(f, hdr)
+	require.NoError(t, err)
+
+	// A signal of the wrong length would misalign every following record, so the
+	// record is rejected without writing anything.
+	require.ErrorContains(t, ew.WriteRecord([][]float64{{1, 2, 3}, {97}}), "signal 0: expected 4 samples, got 3")
+	require.ErrorContains(t, ew.WriteRecord([][]float64{{1, 2, 3, 4}, {97, 96}}), "signal 1: expected 1 samples, got 2")
+	require.Equal(t, 0, ew.RecordsWritten())
+
+	require.NoError(t, ew.WriteRecord([][]float64{{1, 2, 3, 4}, {97}}))
+	require.NoError(t, ew.Close())
+
+	info, err := f.Stat()
+	require.NoError(t, err)
+	require.Equal(t, int64(3*256+10), info.Size())
+}
+
+func TestHeaderMarshal(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		PatientID:          "MCH-0234567 F 02-MAY-1951 Haagse_Harry",
+		RecordingID:        "Startdate 12-DEC-2024 PSG-1234/2024 NN Telemetry03",
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 15, 0, time.UTC),
+		HeaderBytes:        768,
+		Reserved:           "EDF+C",
+		Type:               edf.EDFPlusContinuous,
+		DataRecordDuration: 500 * time.Millisecond,
+		DataRecords:        10,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:             "EEG Fpz-Cz",
+				TransducerType:    "AgAgCl electrode",
+				PhysicalDimension: "uV",
+				PhysicalMin:       -440.5,
+				PhysicalMax:       510,
+				DigitalMin:        -2048,
+				DigitalMax:        2047,
+				Prefiltering:      "HP:0.1Hz LP:75Hz",
+				SamplesPerRecord:  50,
+			},
+			{
+				Label:            edf.AnnotationsLabel,
+				PhysicalMin:      -1,
+				PhysicalMax:      1,
+				DigitalMin:       -32768,
+				DigitalMax:       32767,
+				SamplesPerRecord: 30,
+			},
+		},
+		BytesPerSample: 2,
+	}
+
+	b, err := hdr.Marshal()
+	require.NoError(t, err)
+	require.Len(t, b, 768)
+	require.Equal(t, "0       MCH-0234567", string(b[:19]))
+
+	parsed, err := edf.ParseHeader(b)
+	require.NoError(t, err)
+	require.Equal(t, hdr, *parsed)
+
+	// The same bytes as a writer produces.
+	written := hdr
+	written.DataRecords = 0
+	f := writeTestFile(t, written, nil)
+
+	fileHeader := make([]byte, 768)
+	_, err = io.ReadFull(f, fileHeader)
+	require.NoError(t, err)
+
+	b, err = written.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, fileHeader, b)
+
+	// Invalid headers are rejected.
+	written.SignalCount = 3
+	_, err = written.Marshal()
+	require.Error(t, err)
+}
+
+func TestWriterFlush(t *testing.T) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Flow",
+				PhysicalMin:      -100,
+				PhysicalMax:      100,
+				DigitalMin:       -32768,
+				DigitalMax:       32767,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	f := createTestFile(t)
+
+	ew, err := edf.Create(f, hdr)
+	require.NoError(t, err)
+
+	require.NoError(t, ew.WriteRecord([][]float64{{1, 2, 3, 4}}))
+	require.NoError(t, ew.WriteRecord([][]float64{{5, 6, 7, 8}}))
+
+	// Records are buffered until flushed.
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	require.Equal(t, int64(512), fi.Size())
+
+	require.NoError(t, ew.Flush())
+
+	fi, err = f.Stat()
+	require.NoError(t, err)
+	require.Equal(t, int64(512+2*8), fi.Size())
+
+	// Rewriting the header on Close leaves records written after a flush in place.
+	require.NoError(t, ew.WriteRecord([][]float64{{9, 10, 11, 12}}))
+	require.NoError(t, ew.Close())
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+	require.Equal(t, 3, er.Header().DataRecords)
+
+	samples, err := er.ReadAll(0)
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, samples, 0.01)
+}
+
+func BenchmarkWriterWriteRecord(b *testing.B) {
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        2,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "Flow",
+				PhysicalMin:      -100,
+				PhysicalMax:      100,
+				DigitalMin:       -32768,
+				DigitalMax:       32767,
+				SamplesPerRecord: 256,
+			},
+			{
+				Label:            "SpO2",
+				PhysicalMin:      0,
+				PhysicalMax:      100,
+				DigitalMin:       0,
+				DigitalMax:       1000,
+				SamplesPerRecord: 1,
+			},
+		},
+	}
+
+	f, err := os.OpenFile(filepath.Join(b.TempDir(), "bench.edf"), os.O_RDWR|os.O_CREATE, 0o644)
+	require.NoError(b, err)
+	b.Cleanup(func() {
+		require.NoError(b, f.Close())
+	})
+
+	ew, err := edf.Create(f, hdr)
+	require.NoError(b, err)
+
+	record := [][]float64{make([]float64, 256), {97}}
+	for i := range record[0] {
+		record[0][i] = math.Sin(float64(i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := ew.WriteRecord(record); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.StopTimer()
+	require.NoError(b, ew.Close())
+}
+
+func TestWriterInvertedPolarity(t *testing.T) {
+	// A negative amplifier gain is recorded as a physical minimum above the maximum.
+	hdr := edf.Header{
+		Version:            edf.Version0,
+		StartTime:          time.Date(2024, 12, 12, 22, 30, 0, 0, time.UTC),
+		DataRecordDuration: time.Second,
+		SignalCount:        1,
+		Signals: []edf.SignalHeader{
+			{
+				Label:            "EEG Fpz-Cz",
+				PhysicalMin:      100,
+				PhysicalMax:      -100,
+				DigitalMin:       -32768,
+				DigitalMax:       32767,
+				SamplesPerRecord: 4,
+			},
+		},
+	}
+
+	f := writeTestFile(t, hdr, [][][]float64{{{-100, -50, 50, 100}}})
+
+	er, err := edf.Open(f)
+	require.NoError(t, err)
+	require.Equal(t, 100.0, er.Header().Signals[0].PhysicalMin)
+
+	physical, err := er.ReadAll(0)
+	require.NoError(t, err)
+	require.InDeltaSlice(t, []float64{-100, -50, 50, 100}, physical, 0.01)
+}